@@ -10,6 +10,7 @@ const NewOrderForm = ({ onClose }) => {
   const [formData, setFormData] = useState({
     customer_name: "",
     customer_email: "",
+    customer_locale: "pl",
     total_amount: "",
     source: "manual"
   })
@@ -88,6 +89,19 @@ const NewOrderForm = ({ onClose }) => {
             />
           </div>
 
+          <div className="form-group">
+            <label htmlFor="customer_locale">Język powiadomień</label>
+            <select
+              id="customer_locale"
+              name="customer_locale"
+              value={formData.customer_locale}
+              onChange={handleChange}
+            >
+              <option value="pl">Polski</option>
+              <option value="en">English</option>
+            </select>
+          </div>
+
           <div className="form-group">
             <label htmlFor="total_amount">Kwota (zł)</label>
             <input
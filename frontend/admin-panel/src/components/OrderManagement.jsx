@@ -15,42 +15,49 @@ const OrderManagement = () => {
   // Pobierz token i helper z AuthContext
   const { token, isAuthenticated, getAuthHeaders } = useAuth()
 
-  const { lastMessage } = useWebSocket(`ws://${window.location.host}/ws`)
+  const { lastMessage } = useWebSocket(`ws://${window.location.host}/ws`, token)
 
   useEffect(() => { 
     fetchOrders()
   }, [])
 
   useEffect(() => {
-    if (lastMessage && lastMessage.type === 'order_update') {
-      const { order_id, new_status } = lastMessage.payload;
-      
-      // Sprawdzamy czy zamówienie już istnieje
+    // Serwer nie może odtworzyć pominiętych zdarzeń - pobieramy pełny stan
+    if (lastMessage && lastMessage.type === 'resync_required') {
+      fetchOrders();
+      return;
+    }
+    if (!lastMessage) {
+      return;
+    }
+
+    // Zdarzenia zamówień zawierają pełny stan zamówienia - bez ponownego pobierania z API
+    const applyOrder = (order) => {
       setOrders(prevOrders => {
-        const orderExists = prevOrders.find(order => order.id === order_id);
-        
-        if (orderExists) {
-          // Aktualizujemy istniejące zamówienie
-          return prevOrders.map(order => 
-            order.id === order_id 
-              ? { ...order, status: new_status }
-              : order
-          );
-        } else {
-          // Nowe zamówienie - pobieramy z API w następnym cyklu
-          console.log(`Nowe zamówienie #${order_id}, pobieram z API...`);
-          setTimeout(() => fetchOrders(), 100);
-          return prevOrders;
-        }
-      });
-      
-      // Aktualizujemy selectedOrder jeśli to to samo zamówienie
-      setSelectedOrder(prev => {
-        if (prev && prev.id === order_id) {
-          return { ...prev, status: new_status };
-        }
-        return prev;
+        const orderExists = prevOrders.some(o => o.id === order.id);
+        return orderExists
+          ? prevOrders.map(o => (o.id === order.id ? { ...o, ...order } : o))
+          : [order, ...prevOrders];
       });
+      setSelectedOrder(prev => (prev && prev.id === order.id ? { ...prev, ...order } : prev));
+    };
+
+    switch (lastMessage.type) {
+      case 'order_created':
+      case 'order_updated':
+        applyOrder(lastMessage.payload.order);
+        break;
+      case 'order_bulk_update':
+        lastMessage.payload.forEach(event => applyOrder(event.order));
+        break;
+      case 'order_deleted': {
+        const { order_id } = lastMessage.payload;
+        setOrders(prevOrders => prevOrders.filter(order => order.id !== order_id));
+        setSelectedOrder(prev => (prev && prev.id === order_id ? null : prev));
+        break;
+      }
+      default:
+        break;
     }
   }, [lastMessage]);
 
@@ -310,7 +317,7 @@ const OrderManagement = () => {
 
   return (
     <>
-      {currentView === 'list' ? renderOrdersList() : renderOrderDetails()}
+      {currentView === 'list' || !selectedOrder ? renderOrdersList() : renderOrderDetails()}
       {showNewOrderForm && (
         <NewOrderForm
           onClose={() => setShowNewOrderForm(false)}
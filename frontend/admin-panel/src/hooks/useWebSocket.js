@@ -1,44 +1,77 @@
 import { useState, useEffect, useRef } from 'react';
 
-const useWebSocket = (url) => {
+const RECONNECT_DELAY = 3000;
+
+// token - JWT wysyłany w pierwszej wiadomości "auth" (serwer odrzuca połączenia bez tokenu)
+// Po rozłączeniu hook łączy się ponownie, podając numer ostatniego zdarzenia (last_seq),
+// dzięki czemu serwer odtwarza zdarzenia pominięte w czasie przerwy
+const useWebSocket = (url, token) => {
   const [socket, setSocket] = useState(null);
   const [lastMessage, setLastMessage] = useState(null);
   const [connectionStatus, setConnectionStatus] = useState('Connecting');
   const ws = useRef(null);
+  const lastSeq = useRef(0);
 
   useEffect(() => {
-    // Tworzymy połączenie WebSocket
-    ws.current = new WebSocket(url);
-    
-    ws.current.onopen = () => {
-      console.log('WebSocket połączony');
-      setConnectionStatus('Connected');
-      setSocket(ws.current);
-    };
+    if (!token) {
+      setConnectionStatus('Disconnected');
+      return;
+    }
 
-    ws.current.onmessage = (event) => {
-      const data = JSON.parse(event.data);
-      console.log('Otrzymano wiadomość WebSocket:', data);
-      setLastMessage(data);
-    };
+    let closed = false;
+    let reconnectTimer = null;
 
-    ws.current.onclose = () => {
-      console.log('WebSocket rozłączony');
-      setConnectionStatus('Disconnected');
-    };
+    const connect = () => {
+      // Tworzymy połączenie WebSocket
+      ws.current = new WebSocket(url);
+
+      ws.current.onopen = () => {
+        console.log('WebSocket połączony');
+        ws.current.send(JSON.stringify({
+          type: 'auth',
+          payload: { token, last_seq: lastSeq.current },
+        }));
+        setConnectionStatus('Connected');
+        setSocket(ws.current);
+      };
+
+      ws.current.onmessage = (event) => {
+        const data = JSON.parse(event.data);
+        console.log('Otrzymano wiadomość WebSocket:', data);
+        if (data.seq) {
+          lastSeq.current = Math.max(lastSeq.current, data.seq);
+        } else if (data.type === 'authenticated' || data.type === 'resync_required') {
+          lastSeq.current = Math.max(lastSeq.current, data.payload.last_seq || 0);
+        }
+        setLastMessage(data);
+      };
 
-    ws.current.onerror = (error) => {
-      console.error('Błąd WebSocket:', error);
-      setConnectionStatus('Error');
+      ws.current.onclose = (event) => {
+        console.log('WebSocket rozłączony');
+        setConnectionStatus('Disconnected');
+        // 1008 - serwer odrzucił token, ponawianie nic nie da
+        if (!closed && event.code !== 1008) {
+          reconnectTimer = setTimeout(connect, RECONNECT_DELAY);
+        }
+      };
+
+      ws.current.onerror = (error) => {
+        console.error('Błąd WebSocket:', error);
+        setConnectionStatus('Error');
+      };
     };
 
+    connect();
+
     // Cleanup
     return () => {
+      closed = true;
+      clearTimeout(reconnectTimer);
       if (ws.current) {
         ws.current.close();
       }
     };
-  }, [url]);
+  }, [url, token]);
 
   return { socket, lastMessage, connectionStatus };
 };
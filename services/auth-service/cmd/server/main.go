@@ -100,6 +100,7 @@ func main() {
 		protected.Use(authMiddleware.RequireAuth())
 		{
 			protected.GET("/profile", authHandler.Profile)
+			protected.GET("/users/:id", authMiddleware.RequireAdminOrEmployee(), authHandler.GetUser)
 		}
 
 		admin := api.Group("/admin")
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/iDos27/order-management/auth-service/internal/database"
@@ -140,3 +141,31 @@ func (h *AuthHandler) Profile(c *gin.Context) {
 		"user": user,
 	})
 }
+
+// Dane użytkownika po ID - pozwala innym serwisom sprawdzić rolę (np. przy przypisaniu zamówienia)
+func (h *AuthHandler) GetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	err = h.db.QueryRow(`
+	SELECT id, email, role, created_at, updated_at
+	FROM users WHERE id = $1
+	`, id).Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
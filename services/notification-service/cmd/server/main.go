@@ -1,13 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/iDos27/order-management/notification-service/internal/channels"
 	"github.com/iDos27/order-management/notification-service/internal/consumer"
+	"github.com/iDos27/order-management/notification-service/internal/database"
+	"github.com/iDos27/order-management/notification-service/internal/dedup"
+	"github.com/iDos27/order-management/notification-service/internal/digest"
+	"github.com/iDos27/order-management/notification-service/internal/handlers"
+	"github.com/iDos27/order-management/notification-service/internal/history"
 	"github.com/iDos27/order-management/notification-service/internal/notifier"
+	"github.com/iDos27/order-management/notification-service/internal/preferences"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+	"github.com/iDos27/order-management/notification-service/internal/templates"
+	"github.com/iDos27/order-management/notification-service/middleware"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
@@ -19,42 +37,307 @@ func main() {
 	log.Printf("RabbitMQ URL: %s", rabbitmqURL)
 	log.Printf("Nazwa kolejki: %s", queueName)
 
-	// Inicjalizacja notifiera
-	notif, err := notifier.NewNotifier()
+	// Baza danych - historia powiadomień, przetworzone zdarzenia, podsumowania, preferencje
+	db, err := database.NewConnection()
+	if err != nil {
+		log.Fatalf("Błąd połączenia z bazą danych: %v", err)
+	}
+	defer db.Close()
+	store := history.NewStore(db)
+	prefs := preferences.NewStore(db)
+	deferred := preferences.NewDeferredStore(db)
+
+	// Szablony powiadomień (pliki per język i kanał)
+	engine, err := templates.NewEngine(getEnv("TEMPLATES_DIR", "./templates"), getEnv("NOTIFICATION_LOCALE", "pl"))
 	if err != nil {
-		log.Fatalf("Błąd inicjalizacji notifiera: %v", err)
+		log.Fatalf("Błąd wczytywania szablonów: %v", err)
+	}
+
+	// Inicjalizacja notifiera z kanałami wybranymi w NOTIFICATION_CHANNELS
+	locales := notifier.Locales{
+		Default:  engine.DefaultLocale(),
+		Channels: parseChannelLocales(getEnv("NOTIFICATION_CHANNEL_LOCALES", "")),
 	}
+	stopBackground := make(chan struct{})
+	defer close(stopBackground)
+
+	chs := newChannels()
+	rules := newRouter(chs, engine, stopBackground)
+	notif := notifier.NewNotifier(chs, engine, locales, notifier.Options{
+		Router:   rules,
+		Customer: newCustomerEmails(engine),
+		History:  store,
+		Digests:  digest.NewStore(db),
+
+		Preferences: prefs,
+		Deferred:    deferred,
+	})
 	defer notif.Close()
 
+	// Wysyłka podsumowań, których okno minęło (okna w pliku reguł, sekcja "digests")
+	go notif.RunDigests(stopBackground, getEnvDuration("DIGEST_POLL_INTERVAL", 10*time.Second))
+	// Wysyłka powiadomień wstrzymanych na czas ciszy nocnej pracowników
+	go notif.RunDeferred(stopBackground, getEnvDuration("DEFERRED_POLL_INTERVAL", 30*time.Second))
+
 	// Inicjalizacja konsumenta RabbitMQ
-	cons, err := consumer.NewConsumer(rabbitmqURL)
+	cons, err := consumer.NewConsumer(rabbitmqURL, consumer.Config{
+		MaxRetries: getEnvInt("CONSUMER_MAX_RETRIES", 5),
+		RetryDelay: getEnvDuration("CONSUMER_RETRY_DELAY", 30*time.Second),
+
+		ReconnectMinDelay: getEnvDuration("RABBITMQ_RECONNECT_MIN_DELAY", time.Second),
+		ReconnectMaxDelay: getEnvDuration("RABBITMQ_RECONNECT_MAX_DELAY", 30*time.Second),
+		ReconnectGiveUp:   getEnvDuration("RABBITMQ_RECONNECT_GIVE_UP", 5*time.Minute),
+
+		// Wiadomości jednego zamówienia obsługiwane są po kolei, różnych - równolegle
+		Prefetch: getEnvInt("CONSUMER_PREFETCH", 10),
+		Workers:  getEnvInt("CONSUMER_WORKERS", 4),
+		Key:      notifier.OrderKey,
+	})
 	if err != nil {
 		log.Fatalf("Błąd tworzenia konsumenta: %v", err)
 	}
-	defer cons.Close()
 
-	// Handler dla wiadomości z RabbitMQ
-	handler := func(data []byte) {
+	// Deduplikacja ponownie dostarczonych zdarzeń (również po restarcie)
+	processed := dedup.NewStore(db, getEnvDuration("DEDUP_TTL", 7*24*time.Hour))
+	go processed.RunCleanup(stopBackground, time.Hour)
+
+	// Handler dla wiadomości z RabbitMQ - błąd decyduje o ponowieniu lub przeniesieniu do DLQ
+	handler := func(data []byte) error {
+		eventID := notifier.EventID(data)
+		seen, err := processed.Seen(eventID)
+		if err != nil {
+			log.Printf("Błąd sprawdzania przetworzonych zdarzeń: %v", err)
+			return err
+		}
+		if seen {
+			log.Printf("Pomijam zdarzenie %s - zostało już przetworzone", eventID)
+			return nil
+		}
+
 		if err := notif.HandleOrderUpdate(data); err != nil {
 			log.Printf("Błąd obsługi powiadomienia: %v", err)
+			return err
+		}
+		if err := processed.MarkProcessed(eventID); err != nil {
+			log.Printf("Błąd zapisu przetworzonego zdarzenia %s: %v", eventID, err)
 		}
+		return nil
+	}
+
+	// Uruchomienie konsumowania w goroutine; konsument sam wznawia pracę po zerwaniu
+	// połączenia, błąd oznacza, że RabbitMQ nie wrócił przez RABBITMQ_RECONNECT_GIVE_UP
+	consumerErr := make(chan error, 1)
+	go func() {
+		consumerErr <- cons.Run(queueName, handler)
+	}()
+
+	// API HTTP (stan usługi, podgląd szablonów, historia powiadomień, preferencje)
+	authMiddleware := middleware.NewAuthMiddleware()
+	templateHandler := handlers.NewTemplateHandler(engine)
+	notificationHandler := handlers.NewNotificationHandler(store, notif)
+	preferenceHandler := handlers.NewPreferenceHandler(prefs, deferred, notifier.ChannelNames(chs))
+	healthHandler := handlers.NewHealthHandler(cons, chs, db)
+
+	router := gin.Default()
+
+	// Sondy dla Dockera / k8s - bez uwierzytelnienia
+	router.GET("/health", healthHandler.Health)
+	router.GET("/ready", healthHandler.Ready)
+
+	// Własne preferencje - pracownicy i admini
+	me := router.Group("/api/preferences/me")
+	me.Use(authMiddleware.RequireAuth(), authMiddleware.RequireRole(middleware.RoleEmployee, middleware.RoleAdmin))
+	{
+		me.GET("", preferenceHandler.GetMyPreferences)
+		me.PUT("", preferenceHandler.UpdateMyPreferences)
+		me.DELETE("", preferenceHandler.DeleteMyPreferences)
+		me.GET("/deferred", preferenceHandler.ListMyDeferred)
 	}
 
-	// Uruchomienie konsumowania w goroutine
+	api := router.Group("/api")
+	api.Use(authMiddleware.RequireAuth(), authMiddleware.RequireAdmin())
+	{
+		api.GET("/admin/status", healthHandler.Status)
+
+		api.GET("/templates", templateHandler.ListTemplates)
+		api.POST("/templates/reload", templateHandler.ReloadTemplates)
+		api.GET("/templates/:name/preview", templateHandler.PreviewTemplate)
+		api.POST("/templates/:name/preview", templateHandler.PreviewTemplateWithEvent)
+
+		api.GET("/notifications", notificationHandler.ListNotifications)
+		api.GET("/notifications/:id", notificationHandler.GetNotification)
+		api.POST("/notifications/:id/resend", notificationHandler.ResendNotification)
+
+		api.GET("/preferences", preferenceHandler.ListPreferences)
+		api.GET("/preferences/:userId", preferenceHandler.GetPreferences)
+		api.PUT("/preferences/:userId", preferenceHandler.UpdatePreferences)
+		api.DELETE("/preferences/:userId", preferenceHandler.DeletePreferences)
+	}
+
+	port := getEnv("SERVER_PORT", "8084")
+	server := &http.Server{Addr: ":" + port, Handler: router}
 	go func() {
-		if err := cons.StartConsuming(queueName, handler); err != nil {
-			log.Fatalf("Błąd uruchomienia konsumowania: %v", err)
+		log.Printf("API HTTP na porcie %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Błąd serwera HTTP: %v", err)
 		}
 	}()
 
 	log.Println("Notification Service działa. Naciśnij Ctrl+C aby zakończyć.")
 
-	// Graceful shutdown
+	// Graceful shutdown; SIGHUP przeładowuje reguły kierowania
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	var exitErr error
+loop:
+	for {
+		select {
+		case exitErr = <-consumerErr:
+			break loop
+		case sig := <-sigChan:
+			if sig != syscall.SIGHUP {
+				break loop
+			}
+			if rules == nil {
+				continue
+			}
+			if err := rules.Load(); err != nil {
+				log.Printf("Reguły nie zostały przeładowane: %v", err)
+			}
+		}
+	}
 
 	log.Println("Zamykanie Notification Service...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	// Dokończenie obsługi pobranych wiadomości przed zamknięciem kanału
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), getEnvDuration("CONSUMER_DRAIN_TIMEOUT", 30*time.Second))
+	defer drainCancel()
+	if err := cons.Shutdown(drainCtx); err != nil {
+		log.Printf("Zamknięcie konsumenta: %v", err)
+	}
+
+	if exitErr != nil {
+		// Kod wyjścia różny od zera - orkiestrator (Docker, k8s) uruchomi usługę ponownie
+		log.Fatalf("Konsument zakończył pracę: %v", exitErr)
+	}
+}
+
+// newChannels tworzy kanały powiadomień wymienione w NOTIFICATION_CHANNELS (rozdzielone
+// przecinkami: log, smtp, webhook, slack, dbus). Kanał, którego nie da się uruchomić, jest
+// pomijany; jeśli nie działa żaden, powiadomienia trafiają do logu.
+func newChannels() []channels.Channel {
+	timeout := getEnvDuration("NOTIFICATION_TIMEOUT", 10*time.Second)
+
+	var result []channels.Channel
+	for _, name := range strings.Split(getEnv("NOTIFICATION_CHANNELS", "log"), ",") {
+		var ch channels.Channel
+		var err error
+
+		switch name = strings.TrimSpace(name); name {
+		case "":
+			continue
+		case "log":
+			ch = channels.NewLogChannel(os.Stdout)
+		case "smtp":
+			ch, err = channels.NewSMTPChannel(smtpConfig())
+		case "webhook":
+			ch, err = channels.NewWebhookChannel(getEnv("WEBHOOK_URL", ""), getEnv("WEBHOOK_SECRET", ""), timeout)
+		case "slack":
+			ch, err = channels.NewSlackChannel(getEnv("SLACK_WEBHOOK_URL", ""), timeout)
+		case "dbus":
+			ch, err = channels.NewDBusChannel(getEnv("DBUS_OPEN_URL", "http://localhost:30080"))
+		default:
+			log.Fatalf("Nieznany kanał powiadomień: %s (dozwolone: log, smtp, webhook, slack, dbus)", name)
+		}
+
+		if err != nil {
+			log.Printf("OSTRZEŻENIE: Nie można uruchomić kanału %s: %v", name, err)
+			continue
+		}
+		log.Printf("Kanał powiadomień: %s", ch.Name())
+		result = append(result, ch)
+	}
+
+	if len(result) == 0 {
+		log.Println("OSTRZEŻENIE: Brak działających kanałów - powiadomienia trafią do logu")
+		result = append(result, channels.NewLogChannel(os.Stdout))
+	}
+	return result
+}
+
+// newRouter wczytuje reguły kierowania z ROUTING_RULES_FILE (brak - tylko domyślne
+// kierowanie) i sprawdza co ROUTING_RELOAD_INTERVAL, czy plik się zmienił
+func newRouter(chs []channels.Channel, engine *templates.Engine, stop <-chan struct{}) *routing.Router {
+	path := getEnv("ROUTING_RULES_FILE", "")
+	if path == "" {
+		return nil
+	}
+
+	router, err := routing.NewRouter(path, notifier.ChannelNames(chs), engine)
+	if err != nil {
+		log.Fatalf("Błąd wczytywania reguł kierowania: %v", err)
+	}
+	go router.Watch(stop, getEnvDuration("ROUTING_RELOAD_INTERVAL", 5*time.Second))
+	return router
+}
+
+// newCustomerEmails włącza e-maile do klientów (CUSTOMER_EMAILS_ENABLED) dla statusów
+// z CUSTOMER_EMAIL_STATUSES; wysyłka przez serwer SMTP skonfigurowany zmiennymi SMTP_*
+func newCustomerEmails(engine *templates.Engine) *notifier.CustomerEmails {
+	if !getEnvBool("CUSTOMER_EMAILS_ENABLED", false) {
+		return nil
+	}
+
+	smtp, err := channels.NewSMTPChannel(smtpConfig())
+	if err != nil {
+		log.Fatalf("Błąd konfiguracji SMTP dla e-maili do klientów: %v", err)
+	}
+	statuses := splitList(getEnv("CUSTOMER_EMAIL_STATUSES", strings.Join(notifier.CustomerStatuses, ",")))
+	customer, err := notifier.NewCustomerEmails(smtp, engine, statuses)
+	if err != nil {
+		log.Fatalf("Błąd konfiguracji e-maili do klientów: %v", err)
+	}
+	log.Printf("E-maile do klientów włączone dla statusów: %s", strings.Join(statuses, ", "))
+	return customer
+}
+
+func smtpConfig() channels.SMTPConfig {
+	return channels.SMTPConfig{
+		Host:     getEnv("SMTP_HOST", "localhost"),
+		Port:     getEnvInt("SMTP_PORT", 1025),
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+		From:     getEnv("SMTP_FROM", "zamowienia@localhost"),
+		To:       splitList(getEnv("SMTP_TO", "")),
+		Timeout:  getEnvDuration("NOTIFICATION_TIMEOUT", 10*time.Second),
+	}
+}
+
+// parseChannelLocales - język powiadomień per kanał w formacie "slack=en,smtp=pl"
+func parseChannelLocales(value string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range splitList(value) {
+		channel, locale, ok := strings.Cut(item, "=")
+		if !ok {
+			log.Fatalf("Nieprawidłowy wpis NOTIFICATION_CHANNEL_LOCALES: %s (oczekiwano kanał=język)", item)
+		}
+		result[strings.TrimSpace(channel)] = strings.TrimSpace(locale)
+	}
+	return result
+}
+
+// splitList dzieli listę rozdzieloną przecinkami, pomijając puste elementy
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func getEnv(key, defaultValue string) string {
@@ -63,3 +346,30 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		log.Printf("OSTRZEŻENIE: Nieprawidłowa wartość %s=%q - używam %v", key, raw, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,41 @@
+package channels
+
+// Message - powiadomienie niezależne od kanału, którym zostanie dostarczone
+type Message struct {
+	Title string
+	Body  string
+	// Wersja HTML treści (opcjonalna) - używana przez kanały, które ją obsługują (e-mail)
+	HTML string
+	// Adresaci (np. adresy e-mail); pusta lista - domyślni adresaci kanału
+	To []string
+	// Adres webhooka zamiast domyślnego adresu kanału (webhook, Slack)
+	URL string
+	// Zdarzenie, którego dotyczy powiadomienie - dołączane do treści webhooków
+	Event interface{}
+}
+
+// Channel - sposób dostarczania powiadomień (e-mail, webhook, Slack, D-Bus, log)
+type Channel interface {
+	// Name zwraca nazwę kanału używaną w konfiguracji i logach
+	Name() string
+	Send(msg Message) error
+	Close() error
+}
+
+// HasDefaultTarget sprawdza, czy kanał ma domyślnego adresata (adres e-mail, URL webhooka).
+// Kanał bez niego dostarcza tylko powiadomienia z reguł, które wskazują adresata.
+func HasDefaultTarget(ch Channel) bool {
+	if t, ok := ch.(interface{ HasDefaultTarget() bool }); ok {
+		return t.HasDefaultTarget()
+	}
+	return true
+}
+
+// Check sprawdza, czy kanał może dostarczać powiadomienia (np. serwer SMTP przyjmuje
+// połączenia). Kanały bez takiego sprawdzenia uznawane są za dostępne.
+func Check(ch Channel) error {
+	if c, ok := ch.(interface{ Check() error }); ok {
+		return c.Check()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package channels
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// DBusChannel wyświetla natywne powiadomienia systemowe (org.freedesktop.Notifications).
+// Wymaga sesji D-Bus, więc ma sens tylko na stanowisku z pulpitem.
+type DBusChannel struct {
+	conn     *dbus.Conn
+	openURL  string
+	appTitle string
+}
+
+// NewDBusChannel łączy się z D-Bus i nasłuchuje na kliknięcia w powiadomienia -
+// kliknięcie otwiera openURL w przeglądarce
+func NewDBusChannel(openURL string) (*DBusChannel, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("błąd połączenia z D-Bus: %w", err)
+	}
+
+	c := &DBusChannel{conn: conn, openURL: openURL, appTitle: "System Zarządzania Zamówieniami"}
+
+	// Rejestrujemy regułę, aby otrzymywać sygnał ActionInvoked z serwera powiadomień
+	if err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0,
+		"interface='org.freedesktop.Notifications',member='ActionInvoked'").Store(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("błąd dodawania reguły match: %w", err)
+	}
+
+	// Tworzymy kanał dla sygnałów
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	// Uruchamiamy goroutine do obsługi kliknięć w tle
+	go c.handleSignals(signals)
+
+	log.Println("Połączono z D-Bus dla powiadomień systemowych i nasłuchiwania akcji")
+	return c, nil
+}
+
+func (c *DBusChannel) Name() string { return "dbus" }
+
+// handleSignals nasłuchuje na kliknięcia w powiadomienia
+func (c *DBusChannel) handleSignals(signals chan *dbus.Signal) {
+	for v := range signals {
+		// Sprawdzamy, czy to sygnał "ActionInvoked" (kliknięcie w akcję)
+		if v.Name == "org.freedesktop.Notifications.ActionInvoked" {
+			// v.Body[0] to ID powiadomienia (uint32)
+			// v.Body[1] to klucz akcji (string) - szukamy "default"
+			if len(v.Body) >= 2 {
+				actionKey, ok := v.Body[1].(string)
+				if ok && actionKey == "default" {
+					log.Printf("Kliknięto w powiadomienie! Otwieranie %s...", c.openURL)
+					openBrowser(c.openURL)
+				}
+			}
+		}
+	}
+}
+
+func openBrowser(url string) {
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		err = exec.Command("xdg-open", url).Start()
+	case "windows":
+		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		err = exec.Command("open", url).Start()
+	default:
+		err = fmt.Errorf("nieobsługiwana platforma")
+	}
+	if err != nil {
+		log.Printf("Błąd otwierania przeglądarki: %v", err)
+	}
+}
+
+// Send wysyła natywne powiadomienie systemowe przez D-Bus
+func (c *DBusChannel) Send(msg Message) error {
+	obj := c.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+
+	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
+		c.appTitle,                    // app_name
+		uint32(0),                     // replaces_id
+		"",                            // app_icon
+		msg.Title,                     // summary
+		msg.Body,                      // body
+		[]string{"default", "Otwórz"}, // actions
+		map[string]dbus.Variant{ // hints
+			"urgency": dbus.MakeVariant(byte(1)), // normal urgency
+		},
+		int32(5000), // timeout
+	)
+
+	if call.Err != nil {
+		return fmt.Errorf("błąd wywołania D-Bus: %w", call.Err)
+	}
+
+	return nil
+}
+
+// Check sprawdza, czy połączenie z D-Bus jest aktywne
+func (c *DBusChannel) Check() error {
+	if !c.conn.Connected() {
+		return fmt.Errorf("brak połączenia z D-Bus")
+	}
+	return nil
+}
+
+// Close zamyka połączenie D-Bus
+func (c *DBusChannel) Close() error {
+	return c.conn.Close()
+}
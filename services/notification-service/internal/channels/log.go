@@ -0,0 +1,31 @@
+package channels
+
+import (
+	"io"
+	"log"
+	"strings"
+)
+
+// LogChannel wypisuje powiadomienia do logu (domyślnie stdout) - przydatny na serwerach
+// bez środowiska graficznego i podczas testów
+type LogChannel struct {
+	logger *log.Logger
+}
+
+func NewLogChannel(w io.Writer) *LogChannel {
+	return &LogChannel{logger: log.New(w, "[powiadomienie] ", log.LstdFlags)}
+}
+
+func (c *LogChannel) Name() string { return "log" }
+
+func (c *LogChannel) Send(msg Message) error {
+	body := strings.ReplaceAll(strings.TrimSpace(msg.Body), "\n", " | ")
+	if len(msg.To) > 0 {
+		c.logger.Printf("%s: %s (do: %s)", msg.Title, body, strings.Join(msg.To, ", "))
+		return nil
+	}
+	c.logger.Printf("%s: %s", msg.Title, body)
+	return nil
+}
+
+func (c *LogChannel) Close() error { return nil }
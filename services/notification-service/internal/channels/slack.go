@@ -0,0 +1,46 @@
+package channels
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackChannel wysyła powiadomienia na Slack-owy "incoming webhook" (format zgodny
+// również z Mattermost i Rocket.Chat)
+type SlackChannel struct {
+	url    string
+	client *http.Client
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+func NewSlackChannel(url string, timeout time.Duration) (*SlackChannel, error) {
+	return &SlackChannel{url: url, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (c *SlackChannel) Name() string { return "slack" }
+
+func (c *SlackChannel) Send(msg Message) error {
+	body, err := json.Marshal(slackPayload{Text: fmt.Sprintf("*%s*\n%s", msg.Title, msg.Body)})
+	if err != nil {
+		return err
+	}
+	return postJSON(c.client, c.target(msg), body, nil)
+}
+
+func (c *SlackChannel) target(msg Message) string {
+	if msg.URL != "" {
+		return msg.URL
+	}
+	return c.url
+}
+
+func (c *SlackChannel) Check() error { return checkURL(c.url, c.client.Timeout) }
+
+func (c *SlackChannel) HasDefaultTarget() bool { return c.url != "" }
+
+func (c *SlackChannel) Close() error { return nil }
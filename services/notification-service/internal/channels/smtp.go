@@ -0,0 +1,182 @@
+package channels
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Domyślni adresaci - używani, gdy wiadomość nie wskazuje własnych
+	To      []string
+	Timeout time.Duration
+}
+
+// SMTPChannel wysyła powiadomienia e-mailem. STARTTLS jest używany, jeśli serwer go oferuje,
+// a uwierzytelnienie (PLAIN) tylko przy ustawionym użytkowniku - dzięki temu działa również
+// z lokalnymi serwerami testowymi (MailHog, Mailpit)
+type SMTPChannel struct {
+	config SMTPConfig
+}
+
+func NewSMTPChannel(config SMTPConfig) (*SMTPChannel, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("brak adresu serwera SMTP")
+	}
+	if config.From == "" {
+		return nil, fmt.Errorf("brak adresu nadawcy SMTP")
+	}
+	return &SMTPChannel{config: config}, nil
+}
+
+func (c *SMTPChannel) Name() string { return "smtp" }
+
+func (c *SMTPChannel) Send(msg Message) error {
+	to := msg.To
+	if len(to) == 0 {
+		to = c.config.To
+	}
+	if len(to) == 0 {
+		return failure.Permanent(fmt.Errorf("brak adresatów wiadomości e-mail"))
+	}
+
+	addr := net.JoinHostPort(c.config.Host, fmt.Sprint(c.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, c.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("błąd połączenia z serwerem SMTP: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(c.config.Timeout))
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.config.Host}); err != nil {
+			return fmt.Errorf("błąd STARTTLS: %w", err)
+		}
+	}
+	if c.config.Username != "" {
+		auth := smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("błąd uwierzytelnienia SMTP: %w", err)
+		}
+	}
+
+	if err := client.Mail(c.config.From); err != nil {
+		return classifySMTP(err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return classifySMTP(fmt.Errorf("odrzucony adresat %s: %w", rcpt, err))
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMail(c.config.From, to, msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return classifySMTP(err)
+	}
+	return client.Quit()
+}
+
+// classifySMTP - odpowiedzi 5xx serwera SMTP są trwałe (np. nieistniejąca skrzynka),
+// 4xx i błędy połączenia - przejściowe
+func classifySMTP(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return failure.Permanent(err)
+	}
+	return err
+}
+
+// Check sprawdza, czy serwer SMTP przyjmuje połączenia i odpowiada powitaniem
+func (c *SMTPChannel) Check() error {
+	addr := net.JoinHostPort(c.config.Host, fmt.Sprint(c.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, c.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("błąd połączenia z serwerem SMTP: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(c.config.Timeout))
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	return client.Quit()
+}
+
+func (c *SMTPChannel) HasDefaultTarget() bool { return len(c.config.To) > 0 }
+
+func (c *SMTPChannel) Close() error { return nil }
+
+// buildMail składa wiadomość w UTF-8 (treść w base64, temat zakodowany wg RFC 2047).
+// Przy treści HTML wiadomość ma postać multipart/alternative z wersją tekstową i HTML.
+func buildMail(from string, to []string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		writePart(&b, "text/plain", msg.Body)
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain", msg.Body},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", mw.Boundary())
+		writePart(&b, part.contentType, part.content)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", mw.Boundary())
+	return b.Bytes()
+}
+
+// writePart zapisuje nagłówki i treść jednej części wiadomości
+func writePart(b *bytes.Buffer, contentType, content string) {
+	fmt.Fprintf(b, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(b, []byte(content))
+}
+
+// writeBase64Lines koduje treść w liniach po 76 znaków (RFC 2045)
+func writeBase64Lines(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
@@ -0,0 +1,134 @@
+package channels
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+)
+
+const SignatureHeader = "X-Notification-Signature"
+
+// WebhookChannel wysyła powiadomienie jako JSON metodą POST na wskazany adres.
+// Jeśli ustawiono sekret, treść jest podpisana HMAC-SHA256 w nagłówku X-Notification-Signature.
+type WebhookChannel struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+type webhookPayload struct {
+	Title  string      `json:"title"`
+	Body   string      `json:"body"`
+	To     []string    `json:"to,omitempty"`
+	Event  interface{} `json:"event,omitempty"`
+	SentAt time.Time   `json:"sent_at"`
+}
+
+func NewWebhookChannel(url, secret string, timeout time.Duration) (*WebhookChannel, error) {
+	return &WebhookChannel{url: url, secret: secret, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+func (c *WebhookChannel) Send(msg Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Title:  msg.Title,
+		Body:   msg.Body,
+		To:     msg.To,
+		Event:  msg.Event,
+		SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		headers[SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(c.client, c.target(msg), body, headers)
+}
+
+func (c *WebhookChannel) target(msg Message) string {
+	if msg.URL != "" {
+		return msg.URL
+	}
+	return c.url
+}
+
+func (c *WebhookChannel) Check() error { return checkURL(c.url, c.client.Timeout) }
+
+func (c *WebhookChannel) HasDefaultTarget() bool { return c.url != "" }
+
+func (c *WebhookChannel) Close() error { return nil }
+
+// checkURL sprawdza, czy serwer spod domyślnego adresu przyjmuje połączenia (bez wysyłania
+// treści); kanał bez domyślnego adresu używa adresów z reguł, więc nie ma czego sprawdzać
+func checkURL(rawURL string, timeout time.Duration) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("nieprawidłowy adres %q", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), timeout)
+	if err != nil {
+		return fmt.Errorf("błąd połączenia z %s: %w", u.Host, err)
+	}
+	return conn.Close()
+}
+
+// postJSON wysyła treść JSON i traktuje każdą odpowiedź spoza 2xx jako błąd
+func postJSON(client *http.Client, url string, body []byte, headers map[string]string) error {
+	if url == "" {
+		return failure.Permanent(fmt.Errorf("brak adresu webhooka (ani domyślnego, ani w regule)"))
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "notification-service/1.0")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("webhook zwrócił status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+		// 4xx (poza 408 i 429) - odbiorca odrzuca treść, ponowienie nie pomoże
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return failure.Permanent(err)
+		}
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
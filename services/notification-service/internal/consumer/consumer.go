@@ -1,44 +1,150 @@
 package consumer
 
 import (
+	"context"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Nagłówki wiadomości ponawianych i odrzuconych
+const (
+	RetryCountHeader    = "x-retry-count"    // liczba dotychczasowych ponowień
+	ErrorHeader         = "x-error"          // ostatni błąd obsługi
+	FailureReasonHeader = "x-failure-reason" // powód trafienia do DLQ
+	OriginalQueueHeader = "x-original-queue"
+	RetryIDHeader       = "x-retry-id" // identyfikator w kolejce ponowień (kolejność wiadomości klucza)
+)
+
+// Powody trafienia wiadomości do DLQ
+const (
+	ReasonPermanent        = "permanent"
+	ReasonRetriesExhausted = "retries_exhausted"
+	ReasonPoison           = "poison"
+)
+
+// Handler przetwarza treść wiadomości. Błąd oznaczony failure.Permanent kieruje wiadomość
+// od razu do DLQ, pozostałe błędy - do ponowienia z opóźnieniem.
+type Handler func(body []byte) error
+
+type Config struct {
+	// Maksymalna liczba ponowień - po jej przekroczeniu wiadomość trafia do DLQ
+	MaxRetries int
+	// Opóźnienie ponowienia (czas w kolejce <kolejka>.retry)
+	RetryDelay time.Duration
+	// Odstęp między próbami ponownego połączenia - rośnie od Min do Max
+	ReconnectMinDelay time.Duration
+	ReconnectMaxDelay time.Duration
+	// Po tak długim braku połączenia Run zwraca błąd
+	ReconnectGiveUp time.Duration
+	// Maksymalna liczba wiadomości pobranych, a jeszcze niepotwierdzonych
+	Prefetch int
+	// Liczba równolegle pracujących workerów
+	Workers int
+	// Key zwraca klucz kolejności (np. id zamówienia) - wiadomości z tym samym kluczem
+	// obsługuje ten sam worker, po kolei, również gdy któraś z nich jest ponawiana.
+	// Pusty klucz - dowolny worker, bez zachowania kolejności.
+	Key func(body []byte) string
+}
+
+// Znacznik konsumenta, pozwala anulować konsumpcję przy zamykaniu
+const consumerTag = "notification-service"
+
 type Consumer struct {
+	mu      sync.Mutex
 	conn    *amqp.Connection
 	channel *amqp.Channel
 	url     string
+	config  Config
+
+	done      chan struct{}
+	closeOnce sync.Once
+	running   sync.WaitGroup
+
+	stats   counters
+	parking *parking
 }
 
 // Nowe połaczenie z RabbitMQ
-func NewConsumer(amqpURL string) (*Consumer, error) {
-	conn, err := amqp.Dial(amqpURL)
+func NewConsumer(amqpURL string, config Config) (*Consumer, error) {
+	if config.RetryDelay <= 0 {
+		return nil, fmt.Errorf("nieprawidłowe opóźnienie ponowienia: %v", config.RetryDelay)
+	}
+
+	c := &Consumer{
+		url:     amqpURL,
+		config:  config,
+		done:    make(chan struct{}),
+		parking: newParking(),
+	}
+	c.stats.stats.StartedAt = time.Now()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	log.Println("Utworzono połączenie z RabbitMQ")
+	return c, nil
+}
+
+// connect otwiera nowe połączenie i kanał, zamykając poprzednie
+func (c *Consumer) connect() error {
+	conn, err := amqp.Dial(c.url)
 	if err != nil {
-		return nil, fmt.Errorf("błąd łączenia z RabbitMQ: %v", err)
+		return fmt.Errorf("błąd łączenia z RabbitMQ: %v", err)
 	}
 
 	chanel, err := conn.Channel()
 	if err != nil {
-		return nil, fmt.Errorf("błąd tworzenia kanału RabbitMQ: %v", err)
+		conn.Close()
+		return fmt.Errorf("błąd tworzenia kanału RabbitMQ: %v", err)
 	}
 
-	log.Println("Utworzono połączenie z RabbitMQ")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeConnection()
+	c.conn = conn
+	c.channel = chanel
+	return nil
+}
 
-	return &Consumer{
-		conn:    conn,
-		channel: chanel,
-		url:     amqpURL,
-	}, nil
+func (c *Consumer) closeConnection() {
+	if c.channel != nil {
+		c.channel.Close()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *Consumer) closing() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
 }
 
-// Rozpocznij nasłuchiwanie na wiadomości z kolejki
-func (c *Consumer) StartConsuming(queueName string, handler func([]byte)) error {
-	// Deklaracja kolejki
-	queue, err := c.channel.QueueDeclare(
+func retryQueue(queueName string) string         { return queueName + ".retry" }
+func deadLetterExchange(queueName string) string { return queueName + ".dlx" }
+func deadLetterQueue(queueName string) string    { return queueName + ".dlq" }
+
+// declareTopology deklaruje kolejkę główną oraz:
+//   - <kolejka>.retry - wiadomości czekają tam RetryDelay (TTL wiadomości), po czym
+//     RabbitMQ przekazuje je (dead-letter) z powrotem do kolejki głównej
+//   - exchange <kolejka>.dlx z kolejką <kolejka>.dlq - wiadomości, których nie da się obsłużyć
+//
+// Kolejka główna jest deklarowana bez dodatkowych argumentów (tak jak w order-service),
+// dlatego ponowienia i DLQ realizuje konsument, publikując kopię wiadomości.
+func (c *Consumer) declareTopology(queueName string) error {
+	_, err := c.channel.QueueDeclare(
 		queueName,
 		true,  // durable
 		false, // delete when unused
@@ -50,93 +156,448 @@ func (c *Consumer) StartConsuming(queueName string, handler func([]byte)) error
 		return fmt.Errorf("BŁĄD deklaracji kolejki: %w", err)
 	}
 
-	log.Printf("Nasłuchiwanie na kolejce: %s", queue.Name)
+	_, err = c.channel.QueueDeclare(retryQueue(queueName), true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queueName,
+	})
+	if err != nil {
+		return fmt.Errorf("BŁĄD deklaracji kolejki ponowień: %w", err)
+	}
+
+	if err := c.channel.ExchangeDeclare(deadLetterExchange(queueName), amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("BŁĄD deklaracji DLX: %w", err)
+	}
+	if _, err := c.channel.QueueDeclare(deadLetterQueue(queueName), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("BŁĄD deklaracji DLQ: %w", err)
+	}
+	if err := c.channel.QueueBind(deadLetterQueue(queueName), queueName, deadLetterExchange(queueName), false, nil); err != nil {
+		return fmt.Errorf("BŁĄD powiązania DLQ: %w", err)
+	}
+	return nil
+}
+
+// Run nasłuchuje na wiadomości z kolejki i nadzoruje połączenie: po jego zerwaniu
+// (np. restart RabbitMQ) łączy się ponownie z rosnącym odstępem, deklaruje kolejki
+// i wznawia konsumpcję. Zwraca nil po Close, a błąd - gdy połączenie nie wróciło
+// przez ReconnectGiveUp.
+func (c *Consumer) Run(queueName string, handler Handler) error {
+	c.running.Add(1)
+	defer c.running.Done()
+	c.stats.update(func(st *Stats) { st.Queue = queueName })
+
+	var failingSince time.Time
+	delay := c.config.ReconnectMinDelay
+
+	for {
+		started, err := c.consume(queueName, handler)
+		if c.closing() {
+			return nil
+		}
+		if started {
+			// Konsumpcja działała - liczymy awarię od nowa
+			failingSince = time.Time{}
+			delay = c.config.ReconnectMinDelay
+		}
+		if failingSince.IsZero() {
+			failingSince = time.Now()
+		}
+		log.Printf("Przerwano konsumpcję: %v", err)
+
+		// Ponowne łączenie z rosnącym odstępem
+		for {
+			if time.Since(failingSince) >= c.config.ReconnectGiveUp {
+				return fmt.Errorf("brak działającego połączenia z RabbitMQ od %v: %w", time.Since(failingSince).Round(time.Second), err)
+			}
+
+			log.Printf("Ponowne łączenie z RabbitMQ za %v...", delay)
+			select {
+			case <-c.done:
+				return nil
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, c.config.ReconnectMaxDelay)
+
+			if err = c.connect(); err != nil {
+				log.Printf("Nieudane ponowne połączenie: %v", err)
+				continue
+			}
+			log.Println("Skuteczne ponowne połączenie z RabbitMQ")
+			break
+		}
+	}
+}
+
+// consume deklaruje kolejki i przetwarza wiadomości do czasu zamknięcia kanału.
+// Zwraca true, jeśli konsumpcja zdążyła się rozpocząć.
+func (c *Consumer) consume(queueName string, handler Handler) (bool, error) {
+	// Zamknięcie kanału (również wraz z połączeniem) kończy strumień wiadomości;
+	// powód zamknięcia trafia do closed
+	closed := c.channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err := c.declareTopology(queueName); err != nil {
+		return false, err
+	}
+
+	// Potwierdzenia publikacji - wiadomość główna jest potwierdzana (ack) dopiero, gdy
+	// kopia w kolejce ponowień / DLQ została przyjęta przez broker
+	if err := c.channel.Confirm(false); err != nil {
+		return false, fmt.Errorf("BŁĄD włączania potwierdzeń publikacji: %w", err)
+	}
+
+	log.Printf("Nasłuchiwanie na kolejce: %s (ponowienia: %s, DLQ: %s)", queueName, retryQueue(queueName), deadLetterQueue(queueName))
 
 	// Ustawienie prefetch - ile wiadomości może być przetwarzanych jednocześnie
-	err = c.channel.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
+	err := c.channel.Qos(
+		c.config.Prefetch, // prefetch count
+		0,                 // prefetch size
+		false,             // global
 	)
 	if err != nil {
-		return fmt.Errorf("BŁĄD ustawiania QoS: %w", err)
+		return false, fmt.Errorf("BŁĄD ustawiania QoS: %w", err)
 	}
 
 	messages, err := c.channel.Consume(
-		queue.Name, // queue
-		"",         // consumer
-		false,      // auto-ack
-		false,      // exclusive
-		false,      // no-local
-		false,      // no-wait
-		nil,        // args
+		queueName,   // queue
+		consumerTag, // consumer
+		false,       // auto-ack
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // args
 	)
 	if err != nil {
-		return fmt.Errorf("BŁĄD rozpoczęcia konsumpcji: %w", err)
+		return false, fmt.Errorf("BŁĄD rozpoczęcia konsumpcji: %w", err)
 	}
 
 	log.Println("Oczekiwanie na wiadomosci...")
+	c.stats.update(func(st *Stats) { st.Connected = true })
+	defer c.stats.update(func(st *Stats) { st.Connected = false })
 
 	// Przetwarzanie wiadomości
+	c.dispatch(queueName, messages, handler)
+
+	select {
+	case reason, ok := <-closed:
+		if ok && reason != nil {
+			return true, fmt.Errorf("zamknięto kanał RabbitMQ: %w", reason)
+		}
+		return true, fmt.Errorf("zamknięto kanał RabbitMQ")
+	default:
+		// Kanał działa, ale broker anulował konsumpcję (np. usunięto kolejkę)
+		return true, fmt.Errorf("broker anulował konsumpcję")
+	}
+}
+
+// job - wiadomość z kluczem kolejności
+type job struct {
+	msg amqp.Delivery
+	key string
+}
+
+// dispatch rozdziela wiadomości między workery według klucza kolejności i czeka, aż
+// workery obsłużą wszystkie pobrane wiadomości (po zamknięciu strumienia)
+func (c *Consumer) dispatch(queueName string, messages <-chan amqp.Delivery, handler Handler) {
+	// Gdy nie uda się odłożyć kopii wiadomości (do kolejki ponowień lub DLQ), kanał jest
+	// zamykany: RabbitMQ dostarczy ponownie wszystkie niepotwierdzone wiadomości w dotychczasowej
+	// kolejności, a konsument połączy się ponownie z rosnącym odstępem. Zwrot pojedynczej
+	// wiadomości (nack) wpuściłby przed nią kolejne wiadomości zamówienia.
+	broken := make(chan struct{})
+	var breakOnce sync.Once
+	abort := func() {
+		breakOnce.Do(func() {
+			close(broken)
+			c.mu.Lock()
+			channel := c.channel
+			c.mu.Unlock()
+			channel.Close()
+		})
+	}
+
+	workers := make([]chan job, max(c.config.Workers, 1))
+	var wg sync.WaitGroup
+	for i := range workers {
+		// Bufor na prefetch - broker nie wyśle więcej niepotwierdzonych wiadomości,
+		// więc rozdzielanie nie blokuje się na wolnym workerze
+		workers[i] = make(chan job, max(c.config.Prefetch, 1))
+		wg.Add(1)
+		go func(queue <-chan job) {
+			defer wg.Done()
+			for j := range queue {
+				select {
+				case <-broken:
+					// Kanał zamknięty - RabbitMQ dostarczy wiadomość ponownie
+					c.stats.requeued()
+					continue
+				default:
+				}
+				if err := c.process(queueName, j, handler); err != nil {
+					log.Printf("BŁĄD odkładania kopii wiadomości - zamykam kanał, niepotwierdzone wiadomości zostaną dostarczone ponownie: %v", err)
+					c.stats.requeued()
+					abort()
+				}
+			}
+		}(workers[i])
+	}
+
+	next := 0
 	for msg := range messages {
 		log.Printf("Otrzymano wiadomość: %s", string(msg.Body))
+		c.stats.received()
+
+		var key string
+		if c.config.Key != nil {
+			key = c.config.Key(msg.Body)
+		}
+		var worker int
+		if key == "" {
+			worker = next % len(workers)
+			next++
+		} else {
+			hash := fnv.New32a()
+			hash.Write([]byte(key))
+			worker = int(hash.Sum32() % uint32(len(workers)))
+		}
+		workers[worker] <- job{msg: msg, key: key}
+	}
+
+	for _, queue := range workers {
+		close(queue)
+	}
+	wg.Wait()
+}
+
+// process obsługuje wiadomość i decyduje o jej dalszym losie: ack (sukces), ponowienie
+// z opóźnieniem (błąd przejściowy) albo DLQ (błąd trwały, panika, wyczerpane ponowienia).
+// Liczba dotychczasowych ponowień pochodzi z nagłówka x-retry-count, więc limit obowiązuje
+// również po ponownym połączeniu i restarcie usługi. Wiadomość, której klucz ma wcześniejszą
+// wiadomość w kolejce ponowień, jest odkładana za nią (parking). Zwraca błąd, jeśli nie udało
+// się odłożyć kopii wiadomości - wiadomość nie jest wtedy potwierdzana.
+func (c *Consumer) process(queueName string, j job, handler Handler) error {
+	msg := j.msg
+	id, _ := msg.Headers[RetryIDHeader].(string)
+	if c.parking.wait(j.key, id) {
+		return c.park(queueName, j.key, msg, id)
+	}
 
-		// Obsłuż wiadomość
-		handler(msg.Body)
+	err, poisoned := safeHandle(handler, msg.Body)
+	if err == nil {
+		c.parking.done(j.key, id)
+		c.stats.handled(nil)
+		c.ack(msg)
+		return nil
+	}
+
+	retries := retryCount(msg.Headers)
+	var reason string
+	switch {
+	case poisoned:
+		log.Printf("Wiadomość powoduje panikę - przenoszę do DLQ: %v", err)
+		reason = ReasonPoison
+	case failure.IsPermanent(err):
+		log.Printf("Błąd trwały - przenoszę wiadomość do DLQ: %v", err)
+		reason = ReasonPermanent
+	case retries >= c.config.MaxRetries:
+		log.Printf("Wyczerpano ponowienia (%d) - przenoszę wiadomość do DLQ: %v", retries, err)
+		reason = ReasonRetriesExhausted
+	}
 
-		// Potwierdź przetworzenie wiadomości
-		if err := msg.Ack(false); err != nil {
-			log.Printf("BŁĄD potwierdzania wiadomości: %v", err)
+	if reason != "" {
+		if publishErr := c.deadLetter(queueName, msg, err, reason, retries); publishErr != nil {
+			return fmt.Errorf("przekazanie do DLQ: %w", publishErr)
 		}
+		c.parking.done(j.key, id)
+		c.stats.handled(err)
+		c.ack(msg)
+		return nil
+	}
+
+	log.Printf("Błąd przejściowy - ponowienie %d/%d za %v: %v", retries+1, c.config.MaxRetries, c.config.RetryDelay, err)
+	if publishErr := c.retry(queueName, j.key, msg, id, retries+1, err); publishErr != nil {
+		return fmt.Errorf("przekazanie do ponowienia: %w", publishErr)
 	}
+	c.stats.retried(err)
+	c.ack(msg)
 	return nil
 }
 
-// Reconnect próbuje ponownie połączyć się z RabbitMQ
-func (c *Consumer) Reconnect() error {
-	log.Println("Próba ponownego połączenia z RabbitMQ...")
+// park odkłada wiadomość do kolejki ponowień za wcześniejszą wiadomością klucza,
+// bez zwiększania licznika ponowień
+func (c *Consumer) park(queueName, key string, msg amqp.Delivery, id string) error {
+	id = c.parking.id(key, id)
+	headers := copyHeaders(msg.Headers)
+	headers[RetryIDHeader] = id
 
-	// Zamknij istniejące połączenie i kanał
-	if c.channel != nil {
-		c.channel.Close()
+	if err := c.delay(queueName, msg, headers); err != nil {
+		return fmt.Errorf("odłożenie za wcześniejszą wiadomością: %w", err)
 	}
-	if c.conn != nil {
-		c.conn.Close()
+	c.parking.sent(key, id)
+	log.Printf("Wiadomość (klucz %s) czeka na wcześniejszą wiadomość w kolejce ponowień", key)
+	c.stats.parked()
+	c.ack(msg)
+	return nil
+}
+
+func (c *Consumer) ack(msg amqp.Delivery) {
+	// Potwierdź przetworzenie wiadomości
+	if err := msg.Ack(false); err != nil {
+		log.Printf("BŁĄD potwierdzania wiadomości: %v", err)
 	}
+}
 
-	// Spróbuj ponownie połączyć się
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		conn, err := amqp.Dial(c.url)
-		if err != nil {
-			waitTime := time.Duration(i+1) * 2 * time.Second
-			log.Printf("Reconnect nieudany (próba %d/%d): %v. Ponawiam w %v...", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
+// safeHandle wywołuje handler, zamieniając panikę na błąd (wiadomość "zatruta")
+func safeHandle(handler Handler, body []byte) (err error, poisoned bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, poisoned = fmt.Errorf("panika podczas obsługi: %v", r), true
 		}
+	}()
+	return handler(body), false
+}
 
-		channel, err := conn.Channel()
-		if err != nil {
-			conn.Close()
-			log.Printf("Nieudane otwarcie kanału (próba %d/%d): %v", i+1, maxRetries, err)
-			continue
-		}
+// retry odkłada wiadomość do kolejki ponowień z licznikiem ponowień w nagłówku.
+// Wiadomość z kluczem blokuje do czasu obsługi kolejne wiadomości tego klucza.
+func (c *Consumer) retry(queueName, key string, msg amqp.Delivery, id string, attempt int, cause error) error {
+	headers := copyHeaders(msg.Headers)
+	headers[RetryCountHeader] = int32(attempt)
+	headers[ErrorHeader] = truncate(cause.Error(), 500)
+	if key != "" {
+		id = c.parking.id(key, id)
+		headers[RetryIDHeader] = id
+	}
 
-		c.conn = conn
-		c.channel = channel
-		log.Println("Skuteczne ponowne połączenie z RabbitMQ")
-		return nil
+	if err := c.delay(queueName, msg, headers); err != nil {
+		return err
 	}
+	c.parking.sent(key, id)
+	return nil
+}
 
-	return fmt.Errorf("Nie udało się połączyć w %d attempts", maxRetries)
+// delay publikuje kopię wiadomości do kolejki ponowień - wróci do kolejki głównej po RetryDelay
+func (c *Consumer) delay(queueName string, msg amqp.Delivery, headers amqp.Table) error {
+	publishing := republish(msg, headers)
+	publishing.Expiration = strconv.FormatInt(max(c.config.RetryDelay.Milliseconds(), 1), 10)
+	return c.publish("", retryQueue(queueName), publishing)
 }
 
-func (c *Consumer) Close() {
-	if c.channel != nil {
-		c.channel.Close()
+func (c *Consumer) deadLetter(queueName string, msg amqp.Delivery, cause error, reason string, retries int) error {
+	headers := copyHeaders(msg.Headers)
+	headers[RetryCountHeader] = int32(retries)
+	headers[ErrorHeader] = truncate(cause.Error(), 500)
+	headers[FailureReasonHeader] = reason
+	headers[OriginalQueueHeader] = queueName
+
+	return c.publish(deadLetterExchange(queueName), queueName, republish(msg, headers))
+}
+
+// publish publikuje wiadomość i czeka na potwierdzenie brokera
+func (c *Consumer) publish(exchange, routingKey string, publishing amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	confirmation, err := c.channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, publishing)
+	if err != nil {
+		return err
 	}
-	if c.conn != nil {
-		c.conn.Close()
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker odrzucił wiadomość (nack)")
+	}
+	return nil
+}
+
+func republish(msg amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageId,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	}
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	result := amqp.Table{}
+	for k, v := range headers {
+		result[k] = v
+	}
+	return result
+}
+
+// retryCount odczytuje licznik ponowień z nagłówka (typ liczby zależy od nadawcy)
+func retryCount(headers amqp.Table) int {
+	switch v := headers[RetryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int8:
+		return int(v)
+	case uint8:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	default:
+		return 0
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
+
+// Shutdown zatrzymuje pobieranie nowych wiadomości, czeka, aż workery obsłużą już
+// pobrane (najdłużej do upływu ctx), i zamyka połączenie. Wiadomości nieobsłużone
+// przed upływem ctx RabbitMQ dostarczy ponownie.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.Lock()
+	channel := c.channel
+	c.mu.Unlock()
+	if channel != nil {
+		if err := channel.Cancel(consumerTag, false); err != nil {
+			log.Printf("BŁĄD anulowania konsumpcji: %v", err)
+		}
 	}
+
+	drained := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+		log.Println("Obsłużono wszystkie pobrane wiadomości")
+	case <-ctx.Done():
+		err = fmt.Errorf("nie obsłużono pobranych wiadomości przed zamknięciem: %w", ctx.Err())
+	}
+
+	c.Close()
+	return err
+}
+
+// Close zatrzymuje Run i zamyka połączenie bez czekania na workery
+func (c *Consumer) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeConnection()
 	log.Println("Zamknięto połączenie z RabbitMQ")
 }
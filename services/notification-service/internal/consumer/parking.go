@@ -0,0 +1,126 @@
+package consumer
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Wiadomość odłożona do kolejki ponowień blokuje kolejne wiadomości swojego klucza
+// (zamówienia): są one odkładane ("parkowane") do tej samej kolejki za nią. Kolejka
+// ponowień jest FIFO, a wszystkie wiadomości mają ten sam TTL, więc wracają do kolejki
+// głównej w kolejności odłożenia. Obsługiwana jest tylko pierwsza wiadomość klucza -
+// pozostałe wracają do kolejki ponowień, dopóki nie nadejdzie ich kolej. Worker nie
+// czeka więc na ponowienie, a wiadomości innych zamówień są obsługiwane bez opóźnienia.
+//
+// Stan jest przechowywany w pamięci: po restarcie usługi wiadomości wracające z kolejki
+// ponowień są obsługiwane w kolejności nadejścia.
+type parking struct {
+	mu sync.Mutex
+	// Identyfikator instancji - odróżnia identyfikatory nadane przed restartem
+	instance string
+	next     uint64
+	// Wiadomości klucza w kolejce ponowień, w kolejności obsługi
+	keys map[string][]parked
+}
+
+type parked struct {
+	id string
+	// Numer ostatniego odłożenia do kolejki ponowień
+	sent uint64
+}
+
+func newParking() *parking {
+	return &parking{
+		instance: strconv.FormatInt(time.Now().UnixNano(), 36),
+		keys:     make(map[string][]parked),
+	}
+}
+
+// wait sprawdza, czy wiadomość musi poczekać na wcześniejszą wiadomość swojego klucza.
+// Wiadomości odłożone przed nią, które nie wróciły, zostały utracone (np. usunięte
+// z kolejki ponowień) - są pomijane, aby nie blokowały klucza na zawsze.
+func (p *parking) wait(key, id string) bool {
+	if key == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := p.keys[key]
+	i := index(list, id)
+	if i < 0 {
+		return len(list) > 0
+	}
+	kept := list[:0]
+	for j, entry := range list {
+		if j < i && entry.sent < list[i].sent {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	p.keys[key] = kept
+	return kept[0].id != id
+}
+
+// id zwraca identyfikator wiadomości w kolejce ponowień (nowy, jeśli go nie ma)
+func (p *parking) id(key, id string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if index(p.keys[key], id) >= 0 {
+		return id
+	}
+	p.next++
+	return p.instance + "-" + strconv.FormatUint(p.next, 10)
+}
+
+// sent zapisuje odłożenie wiadomości do kolejki ponowień: nowa wiadomość trafia na koniec
+// kolejki klucza, a ponawiana lub oczekująca zachowuje swoje miejsce
+func (p *parking) sent(key, id string) {
+	if key == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	list := p.keys[key]
+	if i := index(list, id); i >= 0 {
+		list[i].sent = p.next
+		return
+	}
+	p.keys[key] = append(list, parked{id: id, sent: p.next})
+}
+
+// done usuwa obsłużoną wiadomość (sukces lub DLQ) - odblokowuje kolejną wiadomość klucza
+func (p *parking) done(key, id string) {
+	if key == "" || id == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := p.keys[key]
+	i := index(list, id)
+	if i < 0 {
+		return
+	}
+	list = append(list[:i], list[i+1:]...)
+	if len(list) == 0 {
+		delete(p.keys, key)
+		return
+	}
+	p.keys[key] = list
+}
+
+func index(list []parked, id string) int {
+	if id == "" {
+		return -1
+	}
+	for i, entry := range list {
+		if entry.id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,157 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stats - stan konsumenta i liczniki obsłużonych wiadomości (od uruchomienia usługi)
+type Stats struct {
+	Queue     string `json:"queue"`
+	Connected bool   `json:"connected"`
+	// Obsłużone pomyślnie (również po ponowieniach) / przeniesione do DLQ
+	Processed    int64 `json:"processed"`
+	DeadLettered int64 `json:"dead_lettered"`
+	// Wiadomości odłożone do kolejki ponowień po błędzie przejściowym
+	Retried int64 `json:"retried"`
+	// Wiadomości odłożone do kolejki ponowień za wcześniejszą wiadomością zamówienia
+	Parked int64 `json:"parked"`
+	// Wiadomości pozostawione do ponownego dostarczenia (nie udało się odłożyć kopii)
+	Requeued int64 `json:"requeued"`
+	// Wiadomości pobrane, a jeszcze nieobsłużone
+	InFlight      int64      `json:"in_flight"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+}
+
+// QueueInfo - liczba wiadomości oczekujących w kolejce i podłączonych konsumentów
+type QueueInfo struct {
+	Name      string `json:"name"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+}
+
+// QueueStats - kolejka główna (zaległości), ponowień i DLQ
+type QueueStats struct {
+	Main  QueueInfo `json:"main"`
+	Retry QueueInfo `json:"retry"`
+	DLQ   QueueInfo `json:"dlq"`
+}
+
+// counters zbiera statystyki konsumenta; bezpieczne dla wielu workerów
+type counters struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (s *counters) update(fn func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.stats)
+}
+
+func (s *counters) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *counters) received() {
+	now := time.Now()
+	s.update(func(st *Stats) {
+		st.InFlight++
+		st.LastMessageAt = &now
+	})
+}
+
+// handled - wiadomość obsłużona (err == nil) albo przeniesiona do DLQ
+func (s *counters) handled(err error) {
+	s.update(func(st *Stats) {
+		st.InFlight--
+		if err == nil {
+			st.Processed++
+			return
+		}
+		st.DeadLettered++
+		setLastError(st, err)
+	})
+}
+
+func (s *counters) retried(err error) {
+	s.update(func(st *Stats) {
+		st.InFlight--
+		st.Retried++
+		setLastError(st, err)
+	})
+}
+
+func (s *counters) parked() {
+	s.update(func(st *Stats) {
+		st.InFlight--
+		st.Parked++
+	})
+}
+
+func (s *counters) requeued() {
+	s.update(func(st *Stats) {
+		st.InFlight--
+		st.Requeued++
+	})
+}
+
+func setLastError(st *Stats, err error) {
+	now := time.Now()
+	st.LastError = truncate(err.Error(), 500)
+	st.LastErrorAt = &now
+}
+
+// Stats zwraca stan konsumenta i liczniki wiadomości
+func (c *Consumer) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// Connected - czy konsument ma połączenie z RabbitMQ i pobiera wiadomości
+func (c *Consumer) Connected() bool {
+	return c.stats.snapshot().Connected
+}
+
+// QueueStats odczytuje liczbę wiadomości w kolejkach. Używa osobnego kanału - błąd
+// (np. brak kolejki) zamyka kanał AMQP, a nie może przerwać konsumpcji.
+func (c *Consumer) QueueStats() (QueueStats, error) {
+	queue := c.stats.snapshot().Queue
+	if queue == "" {
+		return QueueStats{}, fmt.Errorf("konsumpcja nie została uruchomiona")
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil || conn.IsClosed() {
+		return QueueStats{}, fmt.Errorf("brak połączenia z RabbitMQ")
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return QueueStats{}, fmt.Errorf("błąd tworzenia kanału RabbitMQ: %w", err)
+	}
+	defer channel.Close()
+
+	var result QueueStats
+	for _, q := range []struct {
+		info *QueueInfo
+		name string
+	}{
+		{&result.Main, queue},
+		{&result.Retry, retryQueue(queue)},
+		{&result.DLQ, deadLetterQueue(queue)},
+	} {
+		state, err := channel.QueueDeclarePassive(q.name, true, false, false, false, nil)
+		if err != nil {
+			return QueueStats{}, fmt.Errorf("błąd odczytu kolejki %s: %w", q.name, err)
+		}
+		*q.info = QueueInfo{Name: q.name, Messages: state.Messages, Consumers: state.Consumers}
+	}
+	return result, nil
+}
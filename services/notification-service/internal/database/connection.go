@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+type DB struct {
+	*sql.DB
+}
+
+// ConnectionString zwraca parametry połączenia ze zmiennych środowiskowych
+func ConnectionString() string {
+	host := getEnv("DB_HOST", "localhost")
+	port := getEnv("DB_PORT", "5435")
+	user := getEnv("DB_USER", "notifyuser")
+	password := getEnv("DB_PASSWORD", "notifypass")
+	dbname := getEnv("DB_NAME", "notificationsdb")
+
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbname)
+}
+
+func NewConnection() (*DB, error) {
+	db, err := sql.Open("postgres", ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("BŁĄD: nie można otworzyć bazy danych: %v", err)
+	}
+
+	if err = db.Ping(); err != nil {
+		return nil, fmt.Errorf("BŁĄD: nie można połączyć się z bazą danych: %v", err)
+	}
+
+	log.Println("Pomyślnie połączono z bazą danych")
+	return &DB{db}, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,69 @@
+package dedup
+
+import (
+	"log"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/database"
+)
+
+// Store pamięta identyfikatory przetworzonych zdarzeń przez ttl (tabela processed_events),
+// dzięki czemu ponownie dostarczona wiadomość - również po restarcie usługi - jest pomijana
+type Store struct {
+	db  *database.DB
+	ttl time.Duration
+}
+
+func NewStore(db *database.DB, ttl time.Duration) *Store {
+	return &Store{db: db, ttl: ttl}
+}
+
+// Seen sprawdza, czy zdarzenie zostało już przetworzone (i wpis nie wygasł)
+func (s *Store) Seen(eventID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM processed_events WHERE event_id = $1 AND expires_at > NOW())
+	`, eventID).Scan(&exists)
+	return exists, err
+}
+
+// MarkProcessed zapisuje zdarzenie jako przetworzone na kolejne ttl
+func (s *Store) MarkProcessed(eventID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO processed_events (event_id, expires_at)
+		VALUES ($1, NOW() + $2 * INTERVAL '1 second')
+		ON CONFLICT (event_id) DO UPDATE SET processed_at = NOW(), expires_at = EXCLUDED.expires_at
+	`, eventID, int64(s.ttl.Seconds()))
+	return err
+}
+
+// Cleanup usuwa wygasłe wpisy
+func (s *Store) Cleanup() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM processed_events WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunCleanup usuwa wygasłe wpisy co interval do zamknięcia kanału stop
+func (s *Store) RunCleanup(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			removed, err := s.Cleanup()
+			if err != nil {
+				log.Printf("Błąd usuwania wygasłych zdarzeń: %v", err)
+				continue
+			}
+			if removed > 0 {
+				log.Printf("Usunięto %d wygasłych wpisów deduplikacji", removed)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Item - powiadomienie oczekujące na podsumowanie
+type Item struct {
+	ID         int
+	Channel    string
+	Recipients []string
+	URL        string
+	Locale     string
+	EventID    string
+	Payload    json.RawMessage
+	CreatedAt  time.Time
+}
+
+// Batch - powiadomienia jednego odbiorcy (kanał, adresaci, adres, język) do wysłania razem
+type Batch struct {
+	Key   string
+	Items []Item
+}
+
+// Store przechowuje powiadomienia oczekujące na podsumowanie w bazie (tabela digest_items),
+// więc restart usługi ich nie gubi
+type Store struct {
+	db *database.DB
+}
+
+func NewStore(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+func batchKey(item Item) string {
+	sum := sha256.Sum256([]byte(item.Channel + "\x00" + strings.Join(item.Recipients, ",") + "\x00" + item.URL + "\x00" + item.Locale))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add dodaje powiadomienie do podsumowania. Okno liczone jest od pierwszego powiadomienia
+// grupy; to samo zdarzenie dodane ponownie jest pomijane.
+func (s *Store) Add(item Item, window time.Duration) error {
+	recipients := item.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+	key := batchKey(item)
+
+	_, err := s.db.Exec(`
+		INSERT INTO digest_items (digest_key, channel, recipients, url, locale, event_id, payload, flush_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7,
+		        COALESCE((SELECT MIN(flush_at) FROM digest_items WHERE digest_key = $1),
+		                 NOW() + $8 * INTERVAL '1 second'))
+		ON CONFLICT (digest_key, event_id) DO NOTHING
+	`, key, item.Channel, pq.Array(recipients), item.URL, item.Locale, item.EventID, []byte(item.Payload),
+		int64(window.Seconds()))
+	return err
+}
+
+// Claim pobiera grupy, których okno minęło, i przesuwa ich wysyłkę o lease. Wpisy
+// zablokowane przez inną replikę są pomijane (SKIP LOCKED), a przesunięty termin chroni
+// grupę przed ponownym pobraniem w trakcie wysyłki. Wysłaną grupę usuwa Remove; jeśli
+// proces przerwie wysyłkę, grupa zostanie pobrana ponownie po upływie lease.
+func (s *Store) Claim(lease time.Duration) ([]Batch, error) {
+	rows, err := s.db.Query(`
+		WITH claimed AS (
+			UPDATE digest_items SET flush_at = NOW() + $1 * INTERVAL '1 second'
+			WHERE id IN (
+				SELECT id FROM digest_items WHERE flush_at <= NOW() FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, digest_key, channel, recipients, url, locale, event_id, payload, created_at
+		)
+		SELECT * FROM claimed
+		ORDER BY digest_key, created_at, id
+	`, int64(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []Batch
+	for rows.Next() {
+		var item Item
+		var key string
+		var payload []byte
+		err := rows.Scan(&item.ID, &key, &item.Channel, pq.Array(&item.Recipients), &item.URL,
+			&item.Locale, &item.EventID, &payload, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		item.Payload = payload
+		if len(item.Recipients) == 0 {
+			item.Recipients = nil
+		}
+
+		if len(batches) == 0 || batches[len(batches)-1].Key != key {
+			batches = append(batches, Batch{Key: key})
+		}
+		last := &batches[len(batches)-1]
+		last.Items = append(last.Items, item)
+	}
+	return batches, rows.Err()
+}
+
+// Remove usuwa wysłane (lub odrzucone) powiadomienia grupy
+func (s *Store) Remove(batch Batch) error {
+	ids := make([]int64, len(batch.Items))
+	for i, item := range batch.Items {
+		ids[i] = int64(item.ID)
+	}
+	_, err := s.db.Exec(`DELETE FROM digest_items WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// Postpone przesuwa wysyłkę grupy (np. po błędzie kanału)
+func (s *Store) Postpone(batch Batch, delay time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE digest_items SET flush_at = NOW() + $2 * INTERVAL '1 second' WHERE digest_key = $1
+	`, batch.Key, int64(delay.Seconds()))
+	return err
+}
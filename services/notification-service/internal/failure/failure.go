@@ -0,0 +1,44 @@
+package failure
+
+import "errors"
+
+// permanentError oznacza błąd, którego ponowienie nie pomoże (nieprawidłowa wiadomość,
+// odrzucony adresat, brak szablonu). Pozostałe błędy są traktowane jako przejściowe.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent oznacza błąd jako trwały
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent sprawdza, czy błąd jest trwały. Błąd złożony (errors.Join) jest trwały
+// tylko wtedy, gdy wszystkie jego składowe są trwałe - jeśli choć jedna część może się
+// udać przy ponowieniu, warto ponowić.
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*permanentError); ok {
+		return true
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !IsPermanent(e) {
+				return false
+			}
+		}
+		return len(joined.Unwrap()) > 0
+	}
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		return IsPermanent(wrapped)
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/channels"
+	"github.com/iDos27/order-management/notification-service/internal/consumer"
+	"github.com/iDos27/order-management/notification-service/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	consumer *consumer.Consumer
+	channels []channels.Channel
+	db       *database.DB
+}
+
+func NewHealthHandler(cons *consumer.Consumer, chs []channels.Channel, db *database.DB) *HealthHandler {
+	return &HealthHandler{consumer: cons, channels: chs, db: db}
+}
+
+// GET /health - proces działa (liveness)
+func (h *HealthHandler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "service": "notification-service"})
+}
+
+// GET /ready - usługa może przetwarzać powiadomienia: konsument połączony z RabbitMQ,
+// baza i kanały osiągalne; w przeciwnym razie 503 z wynikami sprawdzeń
+func (h *HealthHandler) Ready(c *gin.Context) {
+	rabbitmq := "ok"
+	if !h.consumer.Connected() {
+		rabbitmq = "not connected"
+	}
+	db := h.checkDatabase(c.Request.Context())
+	chs, channelsOK := h.checkChannels()
+
+	status, code := "ready", http.StatusOK
+	if rabbitmq != "ok" || db != "ok" || !channelsOK {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": gin.H{
+			"rabbitmq": rabbitmq,
+			"database": db,
+			"channels": chs,
+		},
+	})
+}
+
+// GET /api/admin/status - stan konsumenta (liczniki, ostatni błąd), zaległości w kolejkach
+// i dostępność kanałów
+func (h *HealthHandler) Status(c *gin.Context) {
+	stats := h.consumer.Stats()
+	chs, _ := h.checkChannels()
+
+	response := gin.H{
+		"consumer": stats,
+		"uptime":   time.Since(stats.StartedAt).Round(time.Second).String(),
+		"database": h.checkDatabase(c.Request.Context()),
+		"channels": chs,
+	}
+	if queues, err := h.consumer.QueueStats(); err != nil {
+		response["queues_error"] = err.Error()
+	} else {
+		response["queues"] = queues
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if err := h.db.PingContext(ctx); err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// checkChannels sprawdza kanały równolegle - wynik per kanał ("ok" lub opis błędu)
+func (h *HealthHandler) checkChannels() (map[string]string, bool) {
+	results := make(map[string]string, len(h.channels))
+	ok := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range h.channels {
+		wg.Add(1)
+		go func(ch channels.Channel) {
+			defer wg.Done()
+			result := "ok"
+			if err := channels.Check(ch); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[ch.Name()] = result
+			ok = ok && result == "ok"
+		}(ch)
+	}
+	wg.Wait()
+	return results, ok
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/history"
+	"github.com/iDos27/order-management/notification-service/internal/notifier"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	store    *history.Store
+	notifier *notifier.Notifier
+}
+
+func NewNotificationHandler(store *history.Store, notif *notifier.Notifier) *NotificationHandler {
+	return &NotificationHandler{store: store, notifier: notif}
+}
+
+// GET /api/notifications - historia powiadomień
+// Filtry: ?order_id=, ?event_id=, ?event=, ?channel=, ?status=sent|failed, ?recipient=,
+// ?from= / ?to= (RFC 3339), stronicowanie ?limit= (domyślnie 50, maks. 500) i ?offset=
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	filter := history.Filter{
+		EventID:   c.Query("event_id"),
+		Event:     c.Query("event"),
+		Channel:   c.Query("channel"),
+		Status:    c.Query("status"),
+		Recipient: c.Query("recipient"),
+		Limit:     50,
+	}
+
+	if filter.Status != "" && filter.Status != history.StatusSent && filter.Status != history.StatusFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status value"})
+		return
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		id, err := strconv.Atoi(orderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order_id value"})
+			return
+		}
+		filter.OrderID = id
+	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		filter.Limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o > 0 {
+		filter.Offset = o
+	}
+	var ok bool
+	if filter.From, ok = parseTimeParam(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseTimeParam(c, "to"); !ok {
+		return
+	}
+
+	notifications, total, err := h.store.List(filter)
+	if err != nil {
+		log.Printf("Błąd pobierania historii powiadomień: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"total":         total,
+		"limit":         filter.Limit,
+		"offset":        filter.Offset,
+	})
+}
+
+// GET /api/notifications/:id - szczegóły powiadomienia (z treścią)
+func (h *NotificationHandler) GetNotification(c *gin.Context) {
+	notification, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// POST /api/notifications/:id/resend - ponowne wysłanie zapisanej treści do tych samych
+// adresatów; wynik zapisywany jest w historii (attempts, status)
+func (h *NotificationHandler) ResendNotification(c *gin.Context) {
+	notification, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	sendErr := h.notifier.Resend(notification)
+	if err := h.store.RecordResend(notification.ID, sendErr); err != nil {
+		log.Printf("Błąd zapisu ponownego wysłania powiadomienia %d: %v", notification.ID, err)
+	}
+
+	updated, err := h.store.Get(notification.ID)
+	if err != nil {
+		updated = notification
+	}
+	if sendErr != nil {
+		log.Printf("Ponowne wysłanie powiadomienia %d nie powiodło się: %v", notification.ID, sendErr)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":        "Failed to resend notification",
+			"details":      sendErr.Error(),
+			"notification": updated,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// find wczytuje powiadomienie z parametru :id, w razie błędu odpowiada 400/404/500
+func (h *NotificationHandler) find(c *gin.Context) (history.Notification, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return history.Notification{}, false
+	}
+
+	notification, err := h.store.Get(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return history.Notification{}, false
+	}
+	if err != nil {
+		log.Printf("Błąd pobierania powiadomienia %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification"})
+		return history.Notification{}, false
+	}
+	return notification, true
+}
+
+// parseTimeParam odczytuje opcjonalny parametr czasu (RFC 3339), w razie błędu odpowiada 400
+func parseTimeParam(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " value (expected RFC 3339)"})
+		return nil, false
+	}
+	return &t, true
+}
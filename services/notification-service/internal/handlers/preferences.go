@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/iDos27/order-management/notification-service/internal/preferences"
+	"github.com/iDos27/order-management/notification-service/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PreferenceHandler struct {
+	store    *preferences.Store
+	deferred *preferences.DeferredStore
+	channels []string // włączone kanały powiadomień
+}
+
+func NewPreferenceHandler(store *preferences.Store, deferred *preferences.DeferredStore, channels []string) *PreferenceHandler {
+	return &PreferenceHandler{store: store, deferred: deferred, channels: channels}
+}
+
+// GET /api/preferences/me - preferencje zalogowanego pracownika (domyślne, jeśli ich nie zapisał)
+func (h *PreferenceHandler) GetMyPreferences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	p, err := h.store.Get(user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, preferences.Preferences{
+			UserID:   user.ID,
+			Email:    user.Email,
+			Timezone: preferences.DefaultTimezone,
+			Events:   map[string][]string{},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Błąd pobierania preferencji użytkownika %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// PUT /api/preferences/me - zapis preferencji zalogowanego pracownika. Adres e-mail zawsze
+// pochodzi z tokenu, a webhook Slacka może zmienić tylko admin (serwis wysyła na niego
+// dane zamówień)
+func (h *PreferenceHandler) UpdateMyPreferences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	p, ok := bindPreferences(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.store.Get(user.ID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Błąd pobierania preferencji użytkownika %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+	if p.SlackURL != "" && p.SlackURL != current.SlackURL {
+		c.JSON(http.StatusForbidden, gin.H{"error": "slack_url can only be changed by an admin"})
+		return
+	}
+
+	p.Email = user.Email
+	p.SlackURL = current.SlackURL
+	h.save(c, user.ID, p)
+}
+
+// DELETE /api/preferences/me - usunięcie preferencji (powiadomienia według samych reguł)
+func (h *PreferenceHandler) DeleteMyPreferences(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	h.remove(c, user.ID)
+}
+
+// GET /api/preferences/me/deferred - powiadomienia wstrzymane na czas ciszy nocnej
+func (h *PreferenceHandler) ListMyDeferred(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	deferred, err := h.deferred.ForUser(user.ID)
+	if err != nil {
+		log.Printf("Błąd pobierania wstrzymanych powiadomień użytkownika %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deferred notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": deferred, "total": len(deferred)})
+}
+
+// GET /api/preferences - preferencje wszystkich pracowników
+func (h *PreferenceHandler) ListPreferences(c *gin.Context) {
+	all, err := h.store.List()
+	if err != nil {
+		log.Printf("Błąd pobierania preferencji: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": all, "total": len(all)})
+}
+
+// GET /api/preferences/:userId - preferencje pracownika
+func (h *PreferenceHandler) GetPreferences(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	p, err := h.store.Get(userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preferences not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Błąd pobierania preferencji użytkownika %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// PUT /api/preferences/:userId - zapis preferencji pracownika (w tym adresu e-mail i webhooka Slacka)
+func (h *PreferenceHandler) UpdatePreferences(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	p, ok := bindPreferences(c)
+	if !ok {
+		return
+	}
+	h.save(c, userID, p)
+}
+
+// DELETE /api/preferences/:userId - usunięcie preferencji pracownika
+func (h *PreferenceHandler) DeletePreferences(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	h.remove(c, userID)
+}
+
+// bindPreferences odczytuje preferencje z treści żądania, w razie błędu odpowiada 400
+func bindPreferences(c *gin.Context) (preferences.Preferences, bool) {
+	var p preferences.Preferences
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return p, false
+	}
+	return p, true
+}
+
+func (h *PreferenceHandler) save(c *gin.Context, userID int, p preferences.Preferences) {
+	p.UserID = userID
+	if err := p.Validate(h.channels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := h.store.Save(p)
+	if err != nil {
+		log.Printf("Błąd zapisu preferencji użytkownika %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+		return
+	}
+	log.Printf("Zapisano preferencje powiadomień użytkownika %d", userID)
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *PreferenceHandler) remove(c *gin.Context, userID int) {
+	err := h.store.Delete(userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preferences not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Błąd usuwania preferencji użytkownika %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete preferences"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// currentUser zwraca zalogowanego użytkownika, w razie błędu odpowiada 401
+func currentUser(c *gin.Context) (*middleware.CurrentUser, bool) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+	return user, true
+}
+
+// userIDParam odczytuje parametr :userId, w razie błędu odpowiada 400
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
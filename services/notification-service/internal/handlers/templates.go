@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/iDos27/order-management/notification-service/internal/notifier"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+	"github.com/iDos27/order-management/notification-service/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateHandler struct {
+	engine *templates.Engine
+}
+
+func NewTemplateHandler(engine *templates.Engine) *TemplateHandler {
+	return &TemplateHandler{engine: engine}
+}
+
+type previewRequest struct {
+	Channel string `json:"channel"`
+	Locale  string `json:"locale"`
+	// Pola zdarzenia nadpisujące przykładowe zdarzenie (opcjonalne)
+	Event json.RawMessage `json:"event"`
+}
+
+// GET /api/templates - lista szablonów z dostępnymi językami i wersjami dla kanałów
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"default_locale": h.engine.DefaultLocale(),
+		"templates":      h.engine.List(),
+	})
+}
+
+// POST /api/templates/reload - wczytanie szablonów z dysku do wysyłki; przy błędzie
+// w użyciu pozostają dotychczasowe szablony
+func (h *TemplateHandler) ReloadTemplates(c *gin.Context) {
+	if err := h.engine.Load(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	log.Println("Przeładowano szablony powiadomień")
+	c.JSON(http.StatusOK, gin.H{
+		"default_locale": h.engine.DefaultLocale(),
+		"templates":      h.engine.List(),
+	})
+}
+
+// GET /api/templates/:name/preview?channel=&locale=&format=html|text
+// Podgląd szablonu dla przykładowego zdarzenia; format=html/text zwraca samą treść
+// (np. do otwarcia w przeglądarce)
+func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
+	req := previewRequest{Channel: c.Query("channel"), Locale: c.Query("locale")}
+	rendered, event, ok := h.render(c, req)
+	if !ok {
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		if rendered.HTML == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template has no HTML version"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	case "text":
+		c.String(http.StatusOK, "%s\n\n%s", rendered.Subject, rendered.Text)
+	default:
+		c.JSON(http.StatusOK, gin.H{"rendered": rendered, "event": event})
+	}
+}
+
+// POST /api/templates/:name/preview - podgląd dla zdarzenia z treści żądania
+// (pola nadpisują przykładowe zdarzenie)
+func (h *TemplateHandler) PreviewTemplateWithEvent(c *gin.Context) {
+	var req previewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+	rendered, event, ok := h.render(c, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rendered": rendered, "event": event})
+}
+
+func (h *TemplateHandler) render(c *gin.Context, req previewRequest) (templates.Rendered, interface{}, bool) {
+	name := c.Param("name")
+
+	// Podsumowanie ma własne dane (DigestSummary), pozostałe szablony - zdarzenie zamówienia
+	var event interface{}
+	if name == routing.DigestTemplate {
+		summary := notifier.SampleDigest()
+		event = &summary
+	} else {
+		notification := notifier.SampleNotification(name)
+		event = &notification
+	}
+	if len(req.Event) > 0 {
+		if err := json.Unmarshal(req.Event, event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event"})
+			return templates.Rendered{}, event, false
+		}
+	}
+
+	// Podgląd pokazuje bieżące pliki, ale nie podmienia szablonów używanych w wysyłce
+	// (do tego służy POST /api/templates/reload)
+	engine, err := h.engine.Fresh()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return templates.Rendered{}, event, false
+	}
+
+	if !engine.Has(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return templates.Rendered{}, event, false
+	}
+
+	locale := req.Locale
+	if notification, ok := event.(*notifier.OrderNotification); ok && locale == "" {
+		locale = notification.CustomerLocale
+	}
+	rendered, err := engine.Render(name, req.Channel, locale, event)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return templates.Rendered{}, event, false
+	}
+	return rendered, event, true
+}
@@ -0,0 +1,267 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Statusy wpisów historii
+const (
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
+
+// Attempt - pojedyncza próba wysłania powiadomienia
+type Attempt struct {
+	EventID    string
+	OrderID    int
+	Event      string
+	Channel    string
+	Template   string
+	Locale     string
+	Recipients []string
+	URL        string
+	Subject    string
+	Body       string
+	HTML       string
+	Payload    interface{} // zdarzenie, z którego powstało powiadomienie
+	Err        error
+}
+
+// Notification - wpis historii powiadomień
+type Notification struct {
+	ID          int             `json:"id"`
+	EventID     string          `json:"event_id"`
+	OrderID     int             `json:"order_id"`
+	Event       string          `json:"event"`
+	Channel     string          `json:"channel"`
+	Template    string          `json:"template"`
+	Locale      string          `json:"locale"`
+	Recipients  []string        `json:"recipients"`
+	URL         string          `json:"url,omitempty"`
+	Subject     string          `json:"subject"`
+	Body        string          `json:"body"`
+	HTML        string          `json:"html,omitempty"`
+	ContentHash string          `json:"content_hash"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	LastError   *string         `json:"last_error"`
+	Attempts    int             `json:"attempts"`
+	SentAt      *time.Time      `json:"sent_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Filter - kryteria listy historii (puste pola nie filtrują)
+type Filter struct {
+	OrderID   int
+	EventID   string
+	Event     string
+	Channel   string
+	Status    string
+	Recipient string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// Store zapisuje historię powiadomień w bazie (tabela notifications)
+type Store struct {
+	db *database.DB
+}
+
+func NewStore(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+// ContentHash zwraca skrót SHA-256 treści powiadomienia
+func ContentHash(subject, body, html string) string {
+	sum := sha256.Sum256([]byte(subject + "\x00" + body + "\x00" + html))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record zapisuje próbę wysyłki. Ponowna próba tej samej wiadomości (to samo zdarzenie,
+// kanał, szablon i adresaci) aktualizuje istniejący wpis i zwiększa licznik prób.
+func (s *Store) Record(a Attempt) (int, error) {
+	payload, err := json.Marshal(a.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	status := StatusSent
+	var lastError *string
+	if a.Err != nil {
+		status = StatusFailed
+		msg := a.Err.Error()
+		lastError = &msg
+	}
+
+	recipients := a.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+
+	var id int
+	err = s.db.QueryRow(`
+		INSERT INTO notifications (event_id, order_id, event, channel, template, locale, recipients, url,
+		                           subject, body, html, content_hash, payload, status, last_error, sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+		        CASE WHEN $14 = 'sent' THEN NOW() END)
+		ON CONFLICT (event_id, channel, template, recipients, url) DO UPDATE SET
+			locale = EXCLUDED.locale,
+			subject = EXCLUDED.subject,
+			body = EXCLUDED.body,
+			html = EXCLUDED.html,
+			content_hash = EXCLUDED.content_hash,
+			status = EXCLUDED.status,
+			last_error = EXCLUDED.last_error,
+			attempts = notifications.attempts + 1,
+			sent_at = COALESCE(EXCLUDED.sent_at, notifications.sent_at),
+			updated_at = NOW()
+		RETURNING id
+	`, a.EventID, a.OrderID, a.Event, a.Channel, a.Template, a.Locale, pq.Array(recipients), a.URL,
+		a.Subject, a.Body, a.HTML, ContentHash(a.Subject, a.Body, a.HTML), payload, status, lastError).Scan(&id)
+	return id, err
+}
+
+// RecordResend zapisuje wynik ręcznego ponownego wysłania wpisu
+func (s *Store) RecordResend(id int, sendErr error) error {
+	status := StatusSent
+	var lastError *string
+	if sendErr != nil {
+		status = StatusFailed
+		msg := sendErr.Error()
+		lastError = &msg
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE notifications
+		SET status = $2, last_error = $3, attempts = attempts + 1,
+		    sent_at = CASE WHEN $2 = 'sent' THEN NOW() ELSE sent_at END, updated_at = NOW()
+		WHERE id = $1
+	`, id, status, lastError)
+	return err
+}
+
+// WasSent sprawdza, czy wiadomość (zdarzenie, kanał, szablon, adresaci) została już wysłana
+func (s *Store) WasSent(eventID, channel, template string, recipients []string, url string) (bool, error) {
+	if recipients == nil {
+		recipients = []string{}
+	}
+	var sent bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE event_id = $1 AND channel = $2 AND template = $3 AND recipients = $4 AND url = $5
+			  AND status = 'sent'
+		)
+	`, eventID, channel, template, pq.Array(recipients), url).Scan(&sent)
+	return sent, err
+}
+
+// SentToRecipient sprawdza, czy adresat dostał już wiadomość z szablonu dla zamówienia
+// (niezależnie od zdarzenia, np. e-mail "wysłano" po ponownej zmianie statusu)
+func (s *Store) SentToRecipient(orderID int, channel, template, recipient string) (bool, error) {
+	var sent bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE order_id = $1 AND channel = $2 AND template = $3 AND $4 = ANY(recipients)
+			  AND status = 'sent'
+		)
+	`, orderID, channel, template, recipient).Scan(&sent)
+	return sent, err
+}
+
+const notificationColumns = `id, event_id, order_id, event, channel, template, locale, recipients, url,
+	subject, body, html, content_hash, payload, status, last_error, attempts, sent_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNotification(row rowScanner) (Notification, error) {
+	var n Notification
+	var payload []byte
+	err := row.Scan(&n.ID, &n.EventID, &n.OrderID, &n.Event, &n.Channel, &n.Template, &n.Locale,
+		pq.Array(&n.Recipients), &n.URL, &n.Subject, &n.Body, &n.HTML, &n.ContentHash, &payload,
+		&n.Status, &n.LastError, &n.Attempts, &n.SentAt, &n.CreatedAt, &n.UpdatedAt)
+	n.Payload = payload
+	if n.Recipients == nil {
+		n.Recipients = []string{}
+	}
+	return n, err
+}
+
+// Get zwraca wpis o podanym id (sql.ErrNoRows, gdy nie istnieje)
+func (s *Store) Get(id int) (Notification, error) {
+	return scanNotification(s.db.QueryRow(`SELECT `+notificationColumns+` FROM notifications WHERE id = $1`, id))
+}
+
+// List zwraca wpisy pasujące do filtra (od najnowszych) oraz łączną liczbę pasujących
+func (s *Store) List(f Filter) ([]Notification, int, error) {
+	where := " WHERE 1=1"
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		where += " AND " + condition + " $" + strconv.Itoa(len(args))
+	}
+
+	if f.OrderID != 0 {
+		add("order_id =", f.OrderID)
+	}
+	if f.EventID != "" {
+		add("event_id =", f.EventID)
+	}
+	if f.Event != "" {
+		add("event =", f.Event)
+	}
+	if f.Channel != "" {
+		add("channel =", f.Channel)
+	}
+	if f.Status != "" {
+		add("status =", f.Status)
+	}
+	if f.Recipient != "" {
+		args = append(args, f.Recipient)
+		where += " AND $" + strconv.Itoa(len(args)) + " = ANY(recipients)"
+	}
+	if f.From != nil {
+		add("created_at >=", *f.From)
+	}
+	if f.To != nil {
+		add("created_at <", *f.To)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM notifications`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications` + where +
+		" ORDER BY created_at DESC, id DESC" +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	rows, err := s.db.Query(query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, total, rows.Err()
+}
@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iDos27/order-management/notification-service/internal/channels"
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+	"github.com/iDos27/order-management/notification-service/internal/templates"
+)
+
+// CustomerStatuses - statusy, o których klient może zostać powiadomiony e-mailem
+var CustomerStatuses = []string{"confirmed", "shipped", "delivered", "cancelled"}
+
+// Kolejność statusów w normalnym przebiegu zamówienia - cofnięcie statusu
+// (np. shipped -> confirmed) nie generuje ponownego e-maila
+var statusRank = map[string]int{
+	"new":       0,
+	"confirmed": 1,
+	"shipped":   2,
+	"delivered": 3,
+}
+
+// CustomerEmails wysyła klientom e-maile o zmianie statusu zamówienia (szablony
+// customer_<status>) w języku klienta. Każdy status z CustomerStatuses można włączyć osobno.
+type CustomerEmails struct {
+	channel   channels.Channel
+	templates *templates.Engine
+	statuses  map[string]bool
+}
+
+func NewCustomerEmails(channel channels.Channel, engine *templates.Engine, statuses []string) (*CustomerEmails, error) {
+	enabled := make(map[string]bool)
+	for _, status := range statuses {
+		if !isCustomerStatus(status) {
+			return nil, fmt.Errorf("nieobsługiwany status e-maili do klientów: %s (dozwolone: %s)",
+				status, strings.Join(CustomerStatuses, ", "))
+		}
+		if !engine.Has(customerTemplate(status)) {
+			return nil, fmt.Errorf("brak szablonu %s", customerTemplate(status))
+		}
+		enabled[status] = true
+	}
+	return &CustomerEmails{channel: channel, templates: engine, statuses: enabled}, nil
+}
+
+func isCustomerStatus(status string) bool {
+	for _, s := range CustomerStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func customerTemplate(status string) string {
+	return "customer_" + status
+}
+
+// shouldSend sprawdza, czy zmiana statusu wymaga e-maila do klienta
+func (e *CustomerEmails) shouldSend(n OrderNotification) bool {
+	if n.Event != "order.status_changed" || !e.statuses[n.Status] || n.Status == n.PreviousStatus {
+		return false
+	}
+	prev, okPrev := statusRank[n.PreviousStatus]
+	curr, okCurr := statusRank[n.Status]
+	return !(okPrev && okCurr && prev > curr)
+}
+
+// message przygotowuje e-mail do klienta w jego języku; zwraca też użyty język
+func (e *CustomerEmails) message(n OrderNotification) (channels.Message, string, error) {
+	if n.CustomerEmail == "" {
+		return channels.Message{}, "", failure.Permanent(fmt.Errorf("zdarzenie nie zawiera adresu e-mail klienta"))
+	}
+
+	msg := channels.Message{To: []string{n.CustomerEmail}, Event: n}
+	rendered, err := e.templates.Render(customerTemplate(n.Status), e.channel.Name(), n.CustomerLocale, n)
+	if err != nil {
+		return msg, n.CustomerLocale, failure.Permanent(err)
+	}
+	msg.Title, msg.Body, msg.HTML = rendered.Subject, rendered.Text, rendered.HTML
+	return msg, rendered.Locale, nil
+}
@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/channels"
+	"github.com/iDos27/order-management/notification-service/internal/digest"
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+	"github.com/iDos27/order-management/notification-service/internal/history"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+)
+
+// Ile zamówień wymienić w podsumowaniu i ile źródeł pokazać
+const (
+	digestMaxOrders  = 10
+	digestTopSources = 3
+)
+
+// Na jak długo pobrane do wysyłki podsumowanie (lub wstrzymane powiadomienie) jest zajęte -
+// inne repliki go nie wyślą, a po awarii procesu w trakcie wysyłki zostanie wysłane ponownie
+const claimLease = 5 * time.Minute
+
+// Odstęp sprawdzania podsumowań, gdy podany jest niedodatni
+const defaultDigestPoll = 10 * time.Second
+
+// DigestSummary - dane szablonu "digest"
+type DigestSummary struct {
+	Count       int                 `json:"count"`        // liczba zdarzeń
+	OrderCount  int                 `json:"order_count"`  // liczba różnych zamówień
+	TotalAmount float64             `json:"total_amount"` // suma kwot zamówień (każde zamówienie raz)
+	Sources     []SourceCount       `json:"sources"`      // najczęstsze źródła zamówień
+	Orders      []OrderNotification `json:"orders"`       // ostatnie zdarzenie każdego zamówienia (najwyżej 10)
+	More        int                 `json:"more"`         // liczba zamówień niewymienionych w Orders
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+}
+
+type SourceCount struct {
+	Source string `json:"source"`
+	Count  int    `json:"count"`
+}
+
+// SampleDigest - przykładowe podsumowanie do podglądu szablonu
+func SampleDigest() DigestSummary {
+	var notifications []OrderNotification
+	for i, source := range []string{"website", "website", "źródło_dwa", "website", "źródło_jeden"} {
+		n := SampleNotification("order_new")
+		n.OrderID += i
+		n.Source = source
+		n.TotalAmount = float64(100 * (i + 1))
+		notifications = append(notifications, n)
+	}
+	now := time.Now()
+	return summarize(notifications, now.Add(-5*time.Minute), now)
+}
+
+// summarize liczy podsumowanie zdarzeń (w kolejności nadejścia)
+func summarize(notifications []OrderNotification, from, to time.Time) DigestSummary {
+	summary := DigestSummary{Count: len(notifications), From: from, To: to}
+
+	latest := make(map[int]OrderNotification)
+	var order []int
+	for _, n := range notifications {
+		if _, ok := latest[n.OrderID]; !ok {
+			order = append(order, n.OrderID)
+		}
+		latest[n.OrderID] = n
+	}
+	summary.OrderCount = len(order)
+
+	sources := make(map[string]int)
+	for _, id := range order {
+		n := latest[id]
+		summary.TotalAmount += n.TotalAmount
+		if n.Source != "" {
+			sources[n.Source]++
+		}
+		if len(summary.Orders) < digestMaxOrders {
+			summary.Orders = append(summary.Orders, n)
+		} else {
+			summary.More++
+		}
+	}
+
+	for source, count := range sources {
+		summary.Sources = append(summary.Sources, SourceCount{Source: source, Count: count})
+	}
+	sort.Slice(summary.Sources, func(i, j int) bool {
+		if summary.Sources[i].Count != summary.Sources[j].Count {
+			return summary.Sources[i].Count > summary.Sources[j].Count
+		}
+		return summary.Sources[i].Source < summary.Sources[j].Source
+	})
+	if len(summary.Sources) > digestTopSources {
+		summary.Sources = summary.Sources[:digestTopSources]
+	}
+	return summary
+}
+
+// addToDigest odkłada powiadomienie do podsumowania, jeśli obejmuje je okno z pliku reguł.
+// Przekroczone SLA i akcje oznaczone "urgent" wysyłane są zawsze od razu.
+func (n *Notifier) addToDigest(notification OrderNotification, d routing.Delivery) (bool, error) {
+	if n.digests == nil || n.router == nil || notification.Event == "order.sla_breached" {
+		return false, nil
+	}
+	window, ok := n.router.DigestWindow(d, notification.Event)
+	if !ok {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return false, failure.Permanent(err)
+	}
+	err = n.digests.Add(digest.Item{
+		Channel:    d.Channel,
+		Recipients: d.To,
+		URL:        d.URL,
+		Locale:     d.Locale,
+		EventID:    notification.EventID,
+		Payload:    payload,
+	}, window)
+	if err != nil {
+		return false, fmt.Errorf("błąd zapisu do podsumowania: %w", err)
+	}
+
+	log.Printf("Zamówienie #%d: powiadomienie %s dodane do podsumowania (okno %v)", notification.OrderID, d.Channel, window)
+	return true, nil
+}
+
+// RunDigests wysyła podsumowania, których okno minęło - sprawdza co interval do zamknięcia
+// kanału stop. Podsumowanie, którego nie udało się wysłać, czeka minutę na kolejną próbę.
+func (n *Notifier) RunDigests(stop <-chan struct{}, interval time.Duration) {
+	if n.digests == nil {
+		return
+	}
+	if interval <= 0 {
+		log.Printf("OSTRZEŻENIE: Nieprawidłowy odstęp sprawdzania podsumowań %v - używam %v", interval, defaultDigestPoll)
+		interval = defaultDigestPoll
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		batches, err := n.digests.Claim(claimLease)
+		if err != nil {
+			log.Printf("Błąd pobierania podsumowań: %v", err)
+			continue
+		}
+		for _, batch := range batches {
+			err := n.sendDigest(batch)
+			switch {
+			case err == nil || failure.IsPermanent(err):
+				if err != nil {
+					log.Printf("Odrzucono podsumowanie (%s): %v", batch.Items[0].Channel, err)
+				}
+				if err := n.digests.Remove(batch); err != nil {
+					log.Printf("Błąd usuwania wysłanego podsumowania: %v", err)
+				}
+			default:
+				log.Printf("Błąd wysyłania podsumowania (%s), ponowienie za minutę: %v", batch.Items[0].Channel, err)
+				if err := n.digests.Postpone(batch, time.Minute); err != nil {
+					log.Printf("Błąd odkładania podsumowania: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func (n *Notifier) sendDigest(batch digest.Batch) error {
+	first := batch.Items[0]
+	ch, ok := n.byName[first.Channel]
+	if !ok {
+		return failure.Permanent(fmt.Errorf("kanał %s nie jest włączony", first.Channel))
+	}
+
+	var notifications []OrderNotification
+	for _, item := range batch.Items {
+		var notification OrderNotification
+		if err := json.Unmarshal(item.Payload, &notification); err != nil {
+			log.Printf("Pomijam nieczytelny wpis podsumowania %d: %v", item.ID, err)
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	summary := summarize(notifications, first.CreatedAt.Local(), time.Now())
+
+	msg := channels.Message{To: first.Recipients, URL: first.URL, Event: summary}
+	rendered, err := n.templates.Render(routing.DigestTemplate, first.Channel, first.Locale, summary)
+	if err == nil {
+		msg.Title, msg.Body, msg.HTML = rendered.Subject, rendered.Text, rendered.HTML
+		err = ch.Send(msg)
+	} else {
+		err = failure.Permanent(err)
+	}
+	n.recordDigest(batch, msg, summary, err)
+	if err == nil {
+		log.Printf("✓ Wysłano podsumowanie %s (%d zdarzeń)", first.Channel, summary.Count)
+	}
+	return err
+}
+
+// recordDigest zapisuje wysyłkę podsumowania w historii (event_id "digest-<id pierwszego wpisu>")
+func (n *Notifier) recordDigest(batch digest.Batch, msg channels.Message, summary DigestSummary, sendErr error) {
+	if n.history == nil {
+		return
+	}
+	first := batch.Items[0]
+	_, err := n.history.Record(history.Attempt{
+		EventID:    fmt.Sprintf("digest-%d", first.ID),
+		Event:      "digest",
+		Channel:    first.Channel,
+		Template:   routing.DigestTemplate,
+		Locale:     first.Locale,
+		Recipients: msg.To,
+		URL:        msg.URL,
+		Subject:    msg.Title,
+		Body:       msg.Body,
+		HTML:       msg.HTML,
+		Payload:    summary,
+		Err:        sendErr,
+	})
+	if err != nil {
+		log.Printf("Błąd zapisu historii podsumowania: %v", err)
+	}
+}
@@ -1,156 +1,395 @@
 package notifier
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os/exec"
-	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/godbus/dbus/v5"
+	"github.com/iDos27/order-management/notification-service/internal/channels"
+	"github.com/iDos27/order-management/notification-service/internal/digest"
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+	"github.com/iDos27/order-management/notification-service/internal/history"
+	"github.com/iDos27/order-management/notification-service/internal/preferences"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+	"github.com/iDos27/order-management/notification-service/internal/templates"
 )
 
 type OrderNotification struct {
-	OrderID      int     `json:"order_id"`
-	CustomerName string  `json:"customer_name"`
-	Status       string  `json:"status"`
-	TotalAmount  float64 `json:"total_amount"`
+	EventID        string  `json:"event_id,omitempty"`
+	Event          string  `json:"event"`
+	OrderID        int     `json:"order_id"`
+	CustomerName   string  `json:"customer_name"`
+	CustomerEmail  string  `json:"customer_email,omitempty"`
+	CustomerLocale string  `json:"customer_locale,omitempty"`
+	Source         string  `json:"source,omitempty"`
+	AssignedTo     *int    `json:"assigned_to,omitempty"`
+	Status         string  `json:"status"`
+	PreviousStatus string  `json:"previous_status,omitempty"`
+	TotalAmount    float64 `json:"total_amount"`
 }
 
+// SampleNotification - przykładowe zdarzenie do podglądu szablonu (zdarzenie i status
+// odpowiadają sytuacji, w której szablon jest używany)
+func SampleNotification(template string) OrderNotification {
+	n := OrderNotification{
+		Event:          "order.status_changed",
+		OrderID:        1024,
+		CustomerName:   "Jan Kowalski",
+		CustomerEmail:  "jan.kowalski@example.com",
+		CustomerLocale: "pl",
+		Source:         "website",
+		Status:         "shipped",
+		PreviousStatus: "confirmed",
+		TotalAmount:    1299.99,
+	}
+	switch {
+	case template == "order_new":
+		n.Event, n.Status, n.PreviousStatus = "order.created", "new", ""
+	case template == "sla_breached":
+		n.Event, n.Status, n.PreviousStatus = "order.sla_breached", "confirmed", ""
+	case strings.HasPrefix(template, "customer_"):
+		n.Status = strings.TrimPrefix(template, "customer_")
+		n.PreviousStatus = "new"
+	}
+	return n
+}
+
+// Locales - język powiadomień dla obsługi: domyślny i opcjonalnie osobny dla kanału
+type Locales struct {
+	Default  string
+	Channels map[string]string
+}
+
+func (l Locales) For(channel string) string {
+	if locale, ok := l.Channels[channel]; ok {
+		return locale
+	}
+	return l.Default
+}
+
+// Notifier zamienia zdarzenia zamówień na powiadomienia dla obsługi (domyślnie wszystkimi
+// kanałami, dodatkowo według reguł kierowania) i opcjonalnie e-maile do klientów
 type Notifier struct {
-	conn *dbus.Conn
+	channels  []channels.Channel
+	byName    map[string]channels.Channel
+	templates *templates.Engine
+	locales   Locales
+	router    *routing.Router
+	customer  *CustomerEmails
+	history   *history.Store
+	digests   *digest.Store
+
+	preferences *preferences.Store
+	deferred    *preferences.DeferredStore
 }
 
-// NewNotifier tworzy nowy notifier z połączeniem D-Bus i nasłuchuje na kliknięcia
-func NewNotifier() (*Notifier, error) {
-	conn, err := dbus.SessionBus()
-	if err != nil {
-		return nil, fmt.Errorf("błąd połączenia z D-Bus: %w", err)
+// Options - opcjonalne elementy notifiera; nil wyłącza daną funkcję
+type Options struct {
+	Router   *routing.Router // reguły kierowania (i okna podsumowań)
+	Customer *CustomerEmails // e-maile do klientów
+	History  *history.Store  // historia powiadomień
+	Digests  *digest.Store   // powiadomienia oczekujące na podsumowanie
+
+	Preferences *preferences.Store         // preferencje pracowników (wymaga Deferred)
+	Deferred    *preferences.DeferredStore // powiadomienia wstrzymane na czas ciszy nocnej
+}
+
+func NewNotifier(chs []channels.Channel, engine *templates.Engine, locales Locales, opts Options) *Notifier {
+	byName := make(map[string]channels.Channel)
+	for _, ch := range chs {
+		byName[ch.Name()] = ch
+	}
+	return &Notifier{
+		channels:  chs,
+		byName:    byName,
+		templates: engine,
+		locales:   locales,
+		router:    opts.Router,
+		customer:  opts.Customer,
+		history:   opts.History,
+		digests:   opts.Digests,
+
+		preferences: opts.Preferences,
+		deferred:    opts.Deferred,
+	}
+}
+
+// HandleOrderUpdate przetwarza wiadomość o aktualizacji zamówienia
+func (n *Notifier) HandleOrderUpdate(data []byte) error {
+	var notification OrderNotification
+	if err := json.Unmarshal(data, &notification); err != nil {
+		return failure.Permanent(fmt.Errorf("błąd parsowania powiadomienia: %w", err))
+	}
+	if notification.EventID == "" {
+		notification.EventID = contentEventID(data)
+	}
+
+	log.Printf("Przetwarzanie powiadomienia dla Zamówienia #%d: %s", notification.OrderID, notification.Status)
+
+	// E-mail do klienta o zmianie statusu
+	var errs []error
+	if n.customer != nil && n.customer.shouldSend(notification) {
+		if err := n.sendCustomerEmail(notification); err != nil {
+			errs = append(errs, fmt.Errorf("błąd wysyłania e-maila do klienta (zamówienie #%d): %w", notification.OrderID, err))
+		} else {
+			log.Printf("✓ E-mail do klienta wysłany dla Zamówienia #%d (status: %s)", notification.OrderID, notification.Status)
+		}
 	}
 
-	n := &Notifier{conn: conn}
+	// Powiadomienia dla obsługi (z uwzględnieniem preferencji i ciszy nocnej pracowników)
+	deliveries, deferred, err := n.applyPreferences(notification, n.route(notification), time.Now())
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	if err := n.hold(notification, deferred); err != nil {
+		errs = append(errs, err)
+	}
+	if len(deliveries) == 0 {
+		if len(deferred) == 0 {
+			log.Printf("Pomijam powiadomienie - brak odbiorców dla zdarzenia %s (status '%s')", notification.Event, notification.Status)
+		}
+		return errors.Join(errs...)
+	}
 
-	// Rejestrujemy regułę, aby otrzymywać sygnał ActionInvoked z serwera powiadomień
-	if err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0,
-		"interface='org.freedesktop.Notifications',member='ActionInvoked'").Store(); err != nil {
-		return nil, fmt.Errorf("błąd dodawania reguły match: %w", err)
+	if err := n.deliver(notification, deliveries); err != nil {
+		errs = append(errs, fmt.Errorf("błąd wysyłania powiadomienia: %w", err))
+	} else {
+		log.Printf("✓ Powiadomienie wysłane pomyślnie dla Zamówienia #%d", notification.OrderID)
 	}
 
-	// Tworzymy kanał dla sygnałów
-	c := make(chan *dbus.Signal, 10)
-	conn.Signal(c)
+	// Błąd jest trwały (wiadomość trafi do DLQ) tylko, jeśli ponowienie nic nie zmieni
+	return errors.Join(errs...)
+}
+
+// EventID zwraca identyfikator zdarzenia z wiadomości; starsze wiadomości bez event_id
+// identyfikuje skrót treści
+func EventID(data []byte) string {
+	var notification struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal(data, &notification); err == nil && notification.EventID != "" {
+		return notification.EventID
+	}
+	return contentEventID(data)
+}
 
-	// Uruchamiamy goroutine do obsługi kliknięć w tle
-	go n.handleSignals(c)
+func contentEventID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
 
-	log.Println("Połączono z D-Bus dla powiadomień systemowych i nasłuchiwania akcji")
-	return n, nil
+// OrderKey zwraca identyfikator zamówienia z wiadomości (klucz kolejności przetwarzania);
+// pusty, gdy wiadomości nie da się odczytać
+func OrderKey(data []byte) string {
+	var notification struct {
+		OrderID int `json:"order_id"`
+	}
+	if err := json.Unmarshal(data, &notification); err != nil || notification.OrderID == 0 {
+		return ""
+	}
+	return strconv.Itoa(notification.OrderID)
 }
 
-// handleSignals nasłuchuje na kliknięcia w powiadomienia
-func (n *Notifier) handleSignals(c chan *dbus.Signal) {
-	for v := range c {
-		// Sprawdzamy, czy to sygnał "ActionInvoked" (kliknięcie w akcję)
-		if v.Name == "org.freedesktop.Notifications.ActionInvoked" {
-			// v.Body[0] to ID powiadomienia (uint32)
-			// v.Body[1] to klucz akcji (string) - szukamy "default"
-			if len(v.Body) >= 2 {
-				actionKey, ok := v.Body[1].(string)
-				if ok && actionKey == "default" {
-					log.Println("Kliknięto w powiadomienie! Otwieranie localhost...")
-					openBrowser("http://localhost:30080")
+// route ustala, dokąd wysłać powiadomienie. Domyślnie nowe zamówienia i przekroczone SLA
+// trafiają do wszystkich kanałów z domyślnym adresatem; reguły kierowania dodają kolejnych odbiorców (lub
+// zastępują domyślne kierowanie, gdy w pliku reguł default_routing = false).
+func (n *Notifier) route(notification OrderNotification) []routing.Delivery {
+	var deliveries []routing.Delivery
+
+	if n.router == nil || n.router.DefaultRouting() {
+		if notification.Event == "order.sla_breached" || notification.Status == "new" {
+			for _, ch := range n.channels {
+				if channels.HasDefaultTarget(ch) {
+					deliveries = append(deliveries, routing.Delivery{Channel: ch.Name()})
 				}
 			}
 		}
 	}
+
+	if n.router != nil {
+		deliveries = append(deliveries, n.router.Route(routing.Event{
+			Name:       notification.Event,
+			Status:     notification.Status,
+			Source:     notification.Source,
+			Amount:     notification.TotalAmount,
+			AssignedTo: notification.AssignedTo,
+		}, time.Now())...)
+	}
+	return deliveries
 }
 
-func openBrowser(url string) {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
+// defaultTemplate - szablon dla zdarzenia, gdy reguła nie wskazuje innego
+func defaultTemplate(notification OrderNotification) string {
+	switch {
+	case notification.Event == "order.sla_breached":
+		return "sla_breached"
+	case notification.Status == "new":
+		return "order_new"
 	default:
-		err = fmt.Errorf("nieobsługiwana platforma")
-	}
-	if err != nil {
-		log.Printf("Błąd otwierania przeglądarki: %v", err)
+		return "order_status_changed"
 	}
 }
 
-// HandleOrderUpdate przetwarza wiadomość o aktualizacji zamówienia
-func (n *Notifier) HandleOrderUpdate(data []byte) error {
-	var notification OrderNotification
-	if err := json.Unmarshal(data, &notification); err != nil {
-		return fmt.Errorf("błąd parsowania powiadomienia: %w", err)
+// withDefaults uzupełnia szablon i język, których reguła nie wskazała
+func (n *Notifier) withDefaults(notification OrderNotification, d routing.Delivery) routing.Delivery {
+	if d.Template == "" {
+		d.Template = defaultTemplate(notification)
+	}
+	if d.Locale == "" {
+		d.Locale = n.locales.For(d.Channel)
 	}
+	return d
+}
 
-	log.Printf("Przetwarzanie powiadomienia dla Zamówienia #%d: %s", notification.OrderID, notification.Status)
+// deliver renderuje szablon osobno dla każdego odbiorcy (własna wersja szablonu dla kanału,
+// język odbiorcy lub kanału) i wysyła powiadomienia; błąd jednego nie blokuje pozostałych
+func (n *Notifier) deliver(notification OrderNotification, deliveries []routing.Delivery) error {
+	var errs []error
+	sent := make(map[string]bool)
 
-	// Wyświetlaj powiadomienie tylko dla nowych zamówień
-	if notification.Status != "new" {
-		log.Printf("Pomijam powiadomienie - status to '%s' (tylko 'new' generuje powiadomienia)", notification.Status)
-		return nil
-	}
+	for _, d := range deliveries {
+		d = n.withDefaults(notification, d)
+		// Ta sama wiadomość z reguły i z domyślnego kierowania jest wysyłana raz
+		key := fmt.Sprintf("%s|%s|%s|%s|%s", d.Channel, d.Template, d.Locale, strings.Join(d.To, ","), d.URL)
+		if sent[key] {
+			continue
+		}
+		sent[key] = true
 
-	title := "Nowe zamówienie"
-	body := fmt.Sprintf("Zamówienie #%d\nKlient: %s\nKwota: %.2f PLN", notification.OrderID, notification.CustomerName, notification.TotalAmount)
+		queued, err := n.addToDigest(notification, d)
+		if err == nil && !queued {
+			err = n.send(notification, d)
+		}
+		if err != nil {
+			if d.Rule != "" {
+				err = fmt.Errorf("reguła %s: %w", d.Rule, err)
+			}
+			log.Printf("Błąd kanału %s: %v", d.Channel, err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.Channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	if err := n.SendNotification(title, body); err != nil {
-		return fmt.Errorf("błąd wysyłania powiadomienia: %w", err)
+func (n *Notifier) send(notification OrderNotification, d routing.Delivery) error {
+	ch, ok := n.byName[d.Channel]
+	if !ok {
+		return failure.Permanent(fmt.Errorf("kanał nie jest włączony"))
+	}
+	// Ponowienie po częściowym błędzie nie wysyła drugi raz wiadomości, które już doszły
+	if n.history != nil {
+		sent, err := n.history.WasSent(notification.EventID, d.Channel, d.Template, d.To, d.URL)
+		if err != nil {
+			return fmt.Errorf("błąd sprawdzania historii: %w", err)
+		}
+		if sent {
+			log.Printf("Pomijam %s (%s) - wiadomość dla zdarzenia %s została już wysłana", d.Channel, d.Template, notification.EventID)
+			return nil
+		}
 	}
 
-	log.Printf("✓ Powiadomienie wysłane pomyślnie dla Zamówienia #%d", notification.OrderID)
-	return nil
+	msg := channels.Message{To: d.To, URL: d.URL, Event: notification}
+	rendered, err := n.templates.Render(d.Template, d.Channel, d.Locale, notification)
+	if err != nil {
+		n.record(notification, d.Channel, d.Template, d.Locale, msg, err)
+		return failure.Permanent(err)
+	}
+	msg.Title, msg.Body, msg.HTML = rendered.Subject, rendered.Text, rendered.HTML
+	return n.sendAndRecord(notification, ch, d.Template, rendered.Locale, msg)
 }
 
-// SendNotification wysyła natywne powiadomienie systemowe przez D-Bus
-func (n *Notifier) SendNotification(title, body string) error {
-	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
-
-	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
-		"System Zarządzania Zamówieniami", // app_name
-		uint32(0),                         // replaces_id
-		"",                                // app_icon
-		title,                             // summary
-		body,                              // body
-		[]string{"default", "Otwórz"},     // actions
-		map[string]dbus.Variant{ // hints
-			"urgency": dbus.MakeVariant(byte(1)), // normal urgency
-		},
-		int32(5000), // timeout
-	)
+func (n *Notifier) sendCustomerEmail(notification OrderNotification) error {
+	template := customerTemplate(notification.Status)
 
-	if call.Err != nil {
-		return fmt.Errorf("błąd wywołania D-Bus: %w", call.Err)
+	// Klient dostaje e-mail o danym statusie zamówienia tylko raz
+	if n.history != nil && notification.CustomerEmail != "" {
+		sent, err := n.history.SentToRecipient(notification.OrderID, n.customer.channel.Name(), template, notification.CustomerEmail)
+		if err != nil {
+			return fmt.Errorf("błąd sprawdzania historii: %w", err)
+		}
+		if sent {
+			log.Printf("Pomijam e-mail %s dla Zamówienia #%d - klient już go otrzymał", template, notification.OrderID)
+			return nil
+		}
+	}
+	msg, locale, err := n.customer.message(notification)
+	if err != nil {
+		if len(msg.To) > 0 {
+			n.record(notification, n.customer.channel.Name(), template, locale, msg, err)
+		}
+		return err
 	}
+	return n.sendAndRecord(notification, n.customer.channel, template, locale, msg)
+}
+
+// sendAndRecord wysyła wiadomość i zapisuje próbę w historii powiadomień
+func (n *Notifier) sendAndRecord(notification OrderNotification, ch channels.Channel, template, locale string, msg channels.Message) error {
+	err := ch.Send(msg)
+	n.record(notification, ch.Name(), template, locale, msg, err)
+	return err
+}
 
-	return nil
+// record zapisuje próbę wysyłki; błąd zapisu nie wstrzymuje powiadomień
+func (n *Notifier) record(notification OrderNotification, channel, template, locale string, msg channels.Message, sendErr error) {
+	if n.history == nil {
+		return
+	}
+	_, err := n.history.Record(history.Attempt{
+		EventID:    notification.EventID,
+		OrderID:    notification.OrderID,
+		Event:      notification.Event,
+		Channel:    channel,
+		Template:   template,
+		Locale:     locale,
+		Recipients: msg.To,
+		URL:        msg.URL,
+		Subject:    msg.Title,
+		Body:       msg.Body,
+		HTML:       msg.HTML,
+		Payload:    notification,
+		Err:        sendErr,
+	})
+	if err != nil {
+		log.Printf("Błąd zapisu historii powiadomienia (zamówienie #%d, kanał %s): %v", notification.OrderID, channel, err)
+	}
 }
 
-// translateStatus tłumaczy status na polski
-func (n *Notifier) translateStatus(status string) string {
-	translations := map[string]string{
-		"new":       "Nowe",
-		"confirmed": "Potwierdzone",
-		"shipped":   "Wysłane",
-		"delivered": "Dostarczone",
-		"cancelled": "Anulowane",
+// Resend wysyła ponownie wiadomość z historii - tę samą treść do tych samych adresatów
+func (n *Notifier) Resend(entry history.Notification) error {
+	ch, ok := n.byName[entry.Channel]
+	if !ok {
+		return fmt.Errorf("kanał %s nie jest włączony", entry.Channel)
 	}
+	return ch.Send(channels.Message{
+		Title: entry.Subject,
+		Body:  entry.Body,
+		HTML:  entry.HTML,
+		To:    entry.Recipients,
+		URL:   entry.URL,
+		Event: entry.Payload,
+	})
+}
 
-	if translated, ok := translations[status]; ok {
-		return translated
+// ChannelNames zwraca nazwy włączonych kanałów
+func ChannelNames(chs []channels.Channel) []string {
+	names := make([]string, 0, len(chs))
+	for _, ch := range chs {
+		names = append(names, ch.Name())
 	}
-	return status
+	return names
 }
 
-// Close zamyka połączenie D-Bus
+// Close zamyka wszystkie kanały
 func (n *Notifier) Close() {
-	if n.conn != nil {
-		n.conn.Close()
+	for _, ch := range n.channels {
+		if err := ch.Close(); err != nil {
+			log.Printf("Błąd zamykania kanału %s: %v", ch.Name(), err)
+		}
 	}
 }
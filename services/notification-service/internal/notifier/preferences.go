@@ -0,0 +1,219 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/failure"
+	"github.com/iDos27/order-management/notification-service/internal/preferences"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+)
+
+// Odstęp sprawdzania wstrzymanych powiadomień, gdy podany jest niedodatni
+const defaultDeferredPoll = 30 * time.Second
+
+// applyPreferences uwzględnia preferencje pracowników: dodaje powiadomienia kanałami, które
+// wybrali, pomija te, z których zrezygnowali, a powiadomienia wypadające w ich ciszy nocnej
+// zwraca osobno (po jednym na pracownika) do wstrzymania
+func (n *Notifier) applyPreferences(notification OrderNotification, deliveries []routing.Delivery, now time.Time) ([]routing.Delivery, []preferences.Deferred, error) {
+	if n.preferences == nil {
+		return deliveries, nil, nil
+	}
+	all, err := n.preferences.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("błąd odczytu preferencji: %w", err)
+	}
+	if len(all) == 0 {
+		return deliveries, nil, nil
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return nil, nil, failure.Permanent(err)
+	}
+
+	byEmail := make(map[string]*preferences.Preferences)
+	bySlack := make(map[string]*preferences.Preferences)
+	for i := range all {
+		p := &all[i]
+		byEmail[strings.ToLower(p.Email)] = p
+		if p.SlackURL != "" {
+			bySlack[p.SlackURL] = p
+		}
+	}
+	// owner - pracownik, do którego trafia wiadomość (adres e-mail lub osobisty webhook Slacka)
+	owner := func(d routing.Delivery, recipient string) *preferences.Preferences {
+		if recipient == "" {
+			if d.Channel != "slack" || d.URL == "" {
+				return nil
+			}
+			return bySlack[d.URL]
+		}
+		return byEmail[strings.ToLower(recipient)]
+	}
+
+	// Kanały wybrane przez pracownika, którymi reguły do niego nie wysyłają
+	reached := make(map[string]bool)
+	for _, d := range deliveries {
+		for _, recipient := range append([]string{""}, d.To...) {
+			if p := owner(d, recipient); p != nil {
+				reached[fmt.Sprintf("%d|%s", p.UserID, d.Channel)] = true
+			}
+		}
+	}
+	for _, p := range all {
+		for _, channel := range p.Events[notification.Event] {
+			if _, enabled := n.byName[channel]; !enabled || reached[fmt.Sprintf("%d|%s", p.UserID, channel)] {
+				continue
+			}
+			d := routing.Delivery{Channel: channel, Locale: p.Locale}
+			if channel == "slack" {
+				d.URL = p.SlackURL
+			} else {
+				d.To = []string{p.Email}
+			}
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	var result []routing.Delivery
+	var deferred []preferences.Deferred
+	urgent := notification.Event == "order.sla_breached"
+	// check - czy wysłać od razu; quiet - wstrzymać do until
+	check := func(p *preferences.Preferences, d routing.Delivery) (send bool, until time.Time, quiet bool) {
+		if wants, decided := p.Wants(notification.Event, d.Channel); decided && !wants {
+			return false, time.Time{}, false
+		}
+		until, quiet = p.QuietUntil(now, urgent || d.Urgent)
+		return !quiet, until, quiet
+	}
+	hold := func(p *preferences.Preferences, d routing.Delivery, until time.Time) {
+		deferred = append(deferred, preferences.Deferred{
+			UserID:     p.UserID,
+			Channel:    d.Channel,
+			Template:   d.Template,
+			Locale:     d.Locale,
+			Recipients: d.To,
+			URL:        d.URL,
+			EventID:    notification.EventID,
+			Payload:    payload,
+			DeliverAt:  until,
+		})
+	}
+
+	for _, d := range deliveries {
+		d = n.withDefaults(notification, d)
+		if p := owner(d, ""); p != nil {
+			send, until, quiet := check(p, d)
+			if quiet {
+				hold(p, d, until)
+			}
+			if send {
+				result = append(result, d)
+			}
+			continue
+		}
+
+		var to []string
+		for _, recipient := range d.To {
+			p := owner(d, recipient)
+			if p == nil {
+				to = append(to, recipient)
+				continue
+			}
+			send, until, quiet := check(p, d)
+			if quiet {
+				single := d
+				single.To = []string{recipient}
+				hold(p, single, until)
+			}
+			if send {
+				to = append(to, recipient)
+			}
+		}
+		if len(d.To) > 0 && len(to) == 0 {
+			continue
+		}
+		d.To = to
+		result = append(result, d)
+	}
+	return result, deferred, nil
+}
+
+// hold zapisuje powiadomienia wstrzymane na czas ciszy nocnej
+func (n *Notifier) hold(notification OrderNotification, deferred []preferences.Deferred) error {
+	for _, d := range deferred {
+		if err := n.deferred.Add(d); err != nil {
+			return fmt.Errorf("błąd wstrzymania powiadomienia %s: %w", d.Channel, err)
+		}
+		log.Printf("Zamówienie #%d: powiadomienie %s dla użytkownika %d wstrzymane do %s (cisza nocna)",
+			notification.OrderID, d.Channel, d.UserID, d.DeliverAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// RunDeferred wysyła powiadomienia, których cisza nocna minęła - sprawdza co interval do
+// zamknięcia kanału stop. Powiadomienie, którego nie udało się wysłać, czeka minutę na
+// kolejną próbę.
+func (n *Notifier) RunDeferred(stop <-chan struct{}, interval time.Duration) {
+	if n.deferred == nil {
+		return
+	}
+	if interval <= 0 {
+		log.Printf("OSTRZEŻENIE: Nieprawidłowy odstęp sprawdzania wstrzymanych powiadomień %v - używam %v", interval, defaultDeferredPoll)
+		interval = defaultDeferredPoll
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		items, err := n.deferred.Claim(claimLease)
+		if err != nil {
+			log.Printf("Błąd pobierania wstrzymanych powiadomień: %v", err)
+			continue
+		}
+		for _, item := range items {
+			err := n.sendDeferred(item)
+			switch {
+			case err == nil || failure.IsPermanent(err):
+				if err != nil {
+					log.Printf("Odrzucono wstrzymane powiadomienie %d (%s): %v", item.ID, item.Channel, err)
+				}
+				if err := n.deferred.Remove(item.ID); err != nil {
+					log.Printf("Błąd usuwania wstrzymanego powiadomienia %d: %v", item.ID, err)
+				}
+			default:
+				log.Printf("Błąd wysyłania wstrzymanego powiadomienia %d (%s), ponowienie za minutę: %v", item.ID, item.Channel, err)
+				if err := n.deferred.Postpone(item.ID, time.Minute); err != nil {
+					log.Printf("Błąd odkładania wstrzymanego powiadomienia %d: %v", item.ID, err)
+				}
+			}
+		}
+	}
+}
+
+func (n *Notifier) sendDeferred(item preferences.Deferred) error {
+	var notification OrderNotification
+	if err := json.Unmarshal(item.Payload, &notification); err != nil {
+		return failure.Permanent(fmt.Errorf("nieczytelne zdarzenie: %w", err))
+	}
+	err := n.send(notification, routing.Delivery{
+		Channel:  item.Channel,
+		Template: item.Template,
+		To:       item.Recipients,
+		URL:      item.URL,
+		Locale:   item.Locale,
+	})
+	if err == nil {
+		log.Printf("✓ Wysłano wstrzymane powiadomienie %s dla Zamówienia #%d (użytkownik %d)", item.Channel, notification.OrderID, item.UserID)
+	}
+	return err
+}
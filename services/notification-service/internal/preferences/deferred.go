@@ -0,0 +1,134 @@
+package preferences
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Deferred - powiadomienie wstrzymane do końca ciszy nocnej odbiorcy
+type Deferred struct {
+	ID         int             `json:"id"`
+	UserID     int             `json:"user_id"`
+	Channel    string          `json:"channel"`
+	Template   string          `json:"template"`
+	Locale     string          `json:"locale"`
+	Recipients []string        `json:"recipients"`
+	URL        string          `json:"url,omitempty"`
+	EventID    string          `json:"event_id"`
+	Payload    json.RawMessage `json:"payload"`
+	DeliverAt  time.Time       `json:"deliver_at"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// DeferredStore przechowuje wstrzymane powiadomienia w bazie (tabela deferred_notifications),
+// więc restart usługi ich nie gubi
+type DeferredStore struct {
+	db *database.DB
+}
+
+func NewDeferredStore(db *database.DB) *DeferredStore {
+	return &DeferredStore{db: db}
+}
+
+// Add wstrzymuje powiadomienie do d.DeliverAt; to samo powiadomienie dodane ponownie
+// jest pomijane
+func (s *DeferredStore) Add(d Deferred) error {
+	recipients := d.Recipients
+	if recipients == nil {
+		recipients = []string{}
+	}
+	// Opóźnienie liczone w bazie - kolumny TIMESTAMP nie przechowują strefy czasowej
+	delay := int64(math.Ceil(time.Until(d.DeliverAt).Seconds()))
+	if delay < 0 {
+		delay = 0
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO deferred_notifications (user_id, channel, template, locale, recipients, url, event_id, payload, deliver_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW() + $9 * INTERVAL '1 second')
+		ON CONFLICT (event_id, channel, template, recipients, url) DO NOTHING
+	`, d.UserID, d.Channel, d.Template, d.Locale, pq.Array(recipients), d.URL, d.EventID, []byte(d.Payload), delay)
+	return err
+}
+
+const deferredColumns = `id, user_id, channel, template, locale, recipients, url, event_id, payload, deliver_at, created_at`
+
+func scanDeferred(row rowScanner) (Deferred, error) {
+	var d Deferred
+	var payload []byte
+	err := row.Scan(&d.ID, &d.UserID, &d.Channel, &d.Template, &d.Locale, pq.Array(&d.Recipients),
+		&d.URL, &d.EventID, &payload, &d.DeliverAt, &d.CreatedAt)
+	d.Payload = payload
+	if len(d.Recipients) == 0 {
+		d.Recipients = nil
+	}
+	return d, err
+}
+
+func (s *DeferredStore) query(where string, args ...interface{}) ([]Deferred, error) {
+	rows, err := s.db.Query(`SELECT `+deferredColumns+` FROM deferred_notifications `+where+` ORDER BY deliver_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAll(rows)
+}
+
+func scanAll(rows *sql.Rows) ([]Deferred, error) {
+	defer rows.Close()
+
+	result := []Deferred{}
+	for rows.Next() {
+		d, err := scanDeferred(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// Claim pobiera powiadomienia, których cisza nocna już minęła, i przesuwa ich wysyłkę
+// o lease. Wpisy zablokowane przez inną replikę są pomijane (SKIP LOCKED), a przesunięty
+// termin chroni je przed ponownym pobraniem w trakcie wysyłki. Wysłane usuwa Remove; jeśli
+// proces przerwie wysyłkę, powiadomienie zostanie pobrane ponownie po upływie lease.
+func (s *DeferredStore) Claim(lease time.Duration) ([]Deferred, error) {
+	rows, err := s.db.Query(`
+		WITH claimed AS (
+			UPDATE deferred_notifications SET deliver_at = NOW() + $1 * INTERVAL '1 second'
+			WHERE id IN (
+				SELECT id FROM deferred_notifications WHERE deliver_at <= NOW() FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+deferredColumns+`
+		)
+		SELECT `+deferredColumns+` FROM claimed ORDER BY deliver_at, id
+	`, int64(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	return scanAll(rows)
+}
+
+// ForUser zwraca powiadomienia wstrzymane dla użytkownika
+func (s *DeferredStore) ForUser(userID int) ([]Deferred, error) {
+	return s.query(`WHERE user_id = $1`, userID)
+}
+
+// Remove usuwa wysłane (lub odrzucone) powiadomienie
+func (s *DeferredStore) Remove(id int) error {
+	_, err := s.db.Exec(`DELETE FROM deferred_notifications WHERE id = $1`, id)
+	return err
+}
+
+// Postpone przesuwa wysyłkę powiadomienia (np. po błędzie kanału)
+func (s *DeferredStore) Postpone(id int, delay time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE deferred_notifications SET deliver_at = NOW() + $2 * INTERVAL '1 second' WHERE id = $1
+	`, id, int64(delay.Seconds()))
+	return err
+}
@@ -0,0 +1,221 @@
+package preferences
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/database"
+	"github.com/iDos27/order-management/notification-service/internal/routing"
+)
+
+// Events - zdarzenia, dla których można wybrać kanały
+var Events = []string{"order.created", "order.status_changed", "order.sla_breached"}
+
+// Channels - kanały, które mogą dostarczyć powiadomienie do konkretnego pracownika
+// (smtp - na adres e-mail, slack - na osobisty webhook slack_url)
+var Channels = []string{"smtp", "slack"}
+
+// DefaultTimezone - strefa czasowa ciszy nocnej, gdy użytkownik jej nie podał
+const DefaultTimezone = "Europe/Warsaw"
+
+// QuietHours - cisza nocna w strefie użytkownika; powiadomienia są wstrzymywane do jej końca
+type QuietHours struct {
+	routing.TimeWindow
+	// Pilne powiadomienia (przekroczone SLA, akcje "urgent") mimo ciszy nocnej
+	AllowUrgent bool `json:"allow_urgent"`
+}
+
+// Preferences - ustawienia powiadomień pracownika
+type Preferences struct {
+	UserID     int         `json:"user_id"`
+	Email      string      `json:"email"`
+	Locale     string      `json:"locale"`
+	Timezone   string      `json:"timezone"`
+	QuietHours *QuietHours `json:"quiet_hours"`
+	// Zdarzenie -> kanały, którymi pracownik chce je dostawać; pusta lista - wcale.
+	// Zdarzenie bez wpisu - decydują reguły kierowania.
+	Events    map[string][]string `json:"events"`
+	SlackURL  string              `json:"slack_url"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Validate sprawdza preferencje; enabled - nazwy włączonych kanałów
+func (p *Preferences) Validate(enabled []string) error {
+	if p.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	if p.Timezone == "" {
+		p.Timezone = DefaultTimezone
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", p.Timezone)
+	}
+	if p.QuietHours != nil {
+		if err := p.QuietHours.Validate(); err != nil {
+			return fmt.Errorf("invalid quiet_hours: %v", err)
+		}
+	}
+	for event, chs := range p.Events {
+		if !contains(Events, event) {
+			return fmt.Errorf("unsupported event %q (allowed: %s)", event, strings.Join(Events, ", "))
+		}
+		for _, ch := range chs {
+			if !contains(Channels, ch) {
+				return fmt.Errorf("unsupported channel %q (allowed: %s)", ch, strings.Join(Channels, ", "))
+			}
+			if !contains(enabled, ch) {
+				return fmt.Errorf("channel %q is not enabled", ch)
+			}
+			if ch == "slack" && p.SlackURL == "" {
+				return fmt.Errorf("slack_url is required for the slack channel")
+			}
+		}
+	}
+	if p.Events == nil {
+		p.Events = map[string][]string{}
+	}
+	return nil
+}
+
+// Location zwraca strefę czasową użytkownika
+func (p *Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	return loc
+}
+
+// Wants - czy użytkownik chce dostawać zdarzenie kanałem; decided == false, gdy nie
+// wybrał kanałów dla zdarzenia (decydują reguły)
+func (p *Preferences) Wants(event, channel string) (wants, decided bool) {
+	chs, ok := p.Events[event]
+	if !ok {
+		return false, false
+	}
+	return contains(chs, channel), true
+}
+
+// QuietUntil zwraca koniec ciszy nocnej, jeśli now w niej wypada
+func (p *Preferences) QuietUntil(now time.Time, urgent bool) (time.Time, bool) {
+	if p.QuietHours == nil || (urgent && p.QuietHours.AllowUrgent) {
+		return time.Time{}, false
+	}
+	local := now.In(p.Location())
+	if !p.QuietHours.Contains(local) {
+		return time.Time{}, false
+	}
+	return p.QuietHours.End(local), true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Store przechowuje preferencje w bazie (tabela user_preferences)
+type Store struct {
+	db *database.DB
+}
+
+func NewStore(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+const preferenceColumns = `user_id, email, locale, timezone, quiet_hours, events, slack_url, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPreferences(row rowScanner) (Preferences, error) {
+	var p Preferences
+	var quietHours, events []byte
+	err := row.Scan(&p.UserID, &p.Email, &p.Locale, &p.Timezone, &quietHours, &events, &p.SlackURL, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	if len(quietHours) > 0 && string(quietHours) != "null" {
+		p.QuietHours = &QuietHours{}
+		if err := json.Unmarshal(quietHours, p.QuietHours); err != nil {
+			return p, err
+		}
+	}
+	if err := json.Unmarshal(events, &p.Events); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// Get zwraca preferencje użytkownika (sql.ErrNoRows, gdy ich nie zapisał)
+func (s *Store) Get(userID int) (Preferences, error) {
+	return scanPreferences(s.db.QueryRow(`SELECT `+preferenceColumns+` FROM user_preferences WHERE user_id = $1`, userID))
+}
+
+// List zwraca preferencje wszystkich użytkowników
+func (s *Store) List() ([]Preferences, error) {
+	rows, err := s.db.Query(`SELECT ` + preferenceColumns + ` FROM user_preferences ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Preferences{}
+	for rows.Next() {
+		p, err := scanPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// Save zapisuje (lub zastępuje) preferencje użytkownika
+func (s *Store) Save(p Preferences) (Preferences, error) {
+	var quietHours interface{} // NULL - bez ciszy nocnej
+	if p.QuietHours != nil {
+		data, err := json.Marshal(p.QuietHours)
+		if err != nil {
+			return p, err
+		}
+		quietHours = data
+	}
+	events, err := json.Marshal(p.Events)
+	if err != nil {
+		return p, err
+	}
+
+	return scanPreferences(s.db.QueryRow(`
+		INSERT INTO user_preferences (user_id, email, locale, timezone, quiet_hours, events, slack_url, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = EXCLUDED.email,
+			locale = EXCLUDED.locale,
+			timezone = EXCLUDED.timezone,
+			quiet_hours = EXCLUDED.quiet_hours,
+			events = EXCLUDED.events,
+			slack_url = EXCLUDED.slack_url,
+			updated_at = NOW()
+		RETURNING `+preferenceColumns,
+		p.UserID, p.Email, p.Locale, p.Timezone, quietHours, events, p.SlackURL))
+}
+
+// Delete usuwa preferencje (powrót do samych reguł kierowania)
+func (s *Store) Delete(userID int) error {
+	result, err := s.db.Exec(`DELETE FROM user_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/iDos27/order-management/notification-service/internal/templates"
+)
+
+type compiledConfig struct {
+	Config
+	location *time.Location
+	windows  []*compiledWindow // okno czasowe per reguła (nil - bez ograniczenia)
+	digests  []time.Duration   // długość okna per podsumowanie
+}
+
+// DigestTemplate - szablon wiadomości z podsumowaniem
+const DigestTemplate = "digest"
+
+// Router wybiera odbiorców powiadomień na podstawie reguł z pliku JSON. Plik jest
+// wczytywany ponownie po zmianie (Watch) - błędna wersja jest odrzucana, a w użyciu
+// pozostają poprzednie reguły.
+type Router struct {
+	path      string
+	channels  map[string]bool
+	templates *templates.Engine
+
+	mu      sync.RWMutex
+	config  *compiledConfig
+	modTime time.Time
+}
+
+// NewRouter wczytuje reguły; channels - nazwy działających kanałów, do których mogą
+// kierować reguły
+func NewRouter(path string, channels []string, engine *templates.Engine) (*Router, error) {
+	r := &Router{path: path, channels: make(map[string]bool), templates: engine}
+	for _, name := range channels {
+		r.channels[name] = true
+	}
+	if err := r.Load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Load wczytuje i sprawdza plik reguł
+func (r *Router) Load() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("błąd odczytu pliku reguł: %w", err)
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("błąd odczytu pliku reguł: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("nieprawidłowy plik reguł %s: %w", r.path, err)
+	}
+	compiled, err := r.compile(cfg)
+	if err != nil {
+		return fmt.Errorf("nieprawidłowy plik reguł %s: %w", r.path, err)
+	}
+
+	r.mu.Lock()
+	r.config = compiled
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+
+	log.Printf("Wczytano %d reguł kierowania powiadomień z %s", len(cfg.Rules), r.path)
+	return nil
+}
+
+func (r *Router) compile(cfg Config) (*compiledConfig, error) {
+	compiled := &compiledConfig{Config: cfg, location: time.Local}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("nieznana strefa czasowa %q", cfg.Timezone)
+		}
+		compiled.location = loc
+	}
+
+	for i, rule := range cfg.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			compiled.Rules[i].Name = name
+		}
+		window, err := compileWindow(rule.When.Time)
+		if err != nil {
+			return nil, fmt.Errorf("reguła %s: %w", name, err)
+		}
+		compiled.windows = append(compiled.windows, window)
+
+		if len(rule.Send) == 0 {
+			return nil, fmt.Errorf("reguła %s: brak akcji \"send\"", name)
+		}
+		for _, action := range rule.Send {
+			if !r.channels[action.Channel] {
+				return nil, fmt.Errorf("reguła %s: kanał %q nie jest włączony", name, action.Channel)
+			}
+			if action.Template != "" && !r.templates.Has(action.Template) {
+				return nil, fmt.Errorf("reguła %s: brak szablonu %q", name, action.Template)
+			}
+		}
+	}
+
+	for i, digest := range cfg.Digests {
+		if !r.channels[digest.Channel] {
+			return nil, fmt.Errorf("podsumowanie #%d: kanał %q nie jest włączony", i+1, digest.Channel)
+		}
+		window, err := time.ParseDuration(digest.Window)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("podsumowanie #%d: nieprawidłowe okno %q (np. \"5m\")", i+1, digest.Window)
+		}
+		compiled.digests = append(compiled.digests, window)
+	}
+	if len(cfg.Digests) > 0 && !r.templates.Has(DigestTemplate) {
+		return nil, fmt.Errorf("brak szablonu %q dla podsumowań", DigestTemplate)
+	}
+	return compiled, nil
+}
+
+// Watch sprawdza co interval, czy plik reguł się zmienił, i wczytuje go ponownie
+func (r *Router) Watch(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(r.path)
+		if err != nil {
+			log.Printf("Błąd sprawdzania pliku reguł: %v", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := !info.ModTime().Equal(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Load(); err != nil {
+			log.Printf("Reguły nie zostały przeładowane: %v", err)
+			// Kolejna próba dopiero po następnej zmianie pliku
+			r.mu.Lock()
+			r.modTime = info.ModTime()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// DefaultRouting - czy obok reguł działa domyślne kierowanie
+func (r *Router) DefaultRouting() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.config.DefaultRouting == nil || *r.config.DefaultRouting
+}
+
+// Route zwraca powiadomienia wynikające z reguł pasujących do zdarzenia
+func (r *Router) Route(ev Event, now time.Time) []Delivery {
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+
+	now = now.In(cfg.location)
+	var deliveries []Delivery
+	for i, rule := range cfg.Rules {
+		if !rule.When.matches(ev, cfg.windows[i], now) {
+			continue
+		}
+		for _, action := range rule.Send {
+			to, locale, ok := cfg.resolveRecipients(action.To, ev)
+			if !ok {
+				log.Printf("Reguła %s: brak adresatów dla kanału %s (zamówienie bez przypisanego pracownika?)", rule.Name, action.Channel)
+				continue
+			}
+			if action.Locale != "" {
+				locale = action.Locale
+			}
+			deliveries = append(deliveries, Delivery{
+				Rule:     rule.Name,
+				Channel:  action.Channel,
+				Template: action.Template,
+				To:       to,
+				URL:      action.URL,
+				Locale:   locale,
+				Urgent:   action.Urgent,
+			})
+		}
+		if rule.Stop {
+			break
+		}
+	}
+	return deliveries
+}
+
+// DigestWindow zwraca okno pierwszego podsumowania obejmującego powiadomienie;
+// pilne powiadomienia nie trafiają do podsumowań
+func (r *Router) DigestWindow(d Delivery, event string) (time.Duration, bool) {
+	if d.Urgent {
+		return 0, false
+	}
+
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+
+	for i, digest := range cfg.Digests {
+		if digest.covers(d, event) {
+			return cfg.digests[i], true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,264 @@
+package routing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config - zawartość pliku reguł (JSON)
+type Config struct {
+	// Czy obok reguł działa domyślne kierowanie (nowe zamówienia i przekroczone SLA do
+	// wszystkich kanałów); brak - true
+	DefaultRouting *bool `json:"default_routing"`
+	// Strefa czasowa warunków "time", np. "Europe/Warsaw"; brak - strefa serwera
+	Timezone string `json:"timezone"`
+	// Pracownicy (klucz - ID użytkownika z auth-service), do których kierują adresy "@assignee"
+	Users map[string]User `json:"users"`
+	Rules []Rule          `json:"rules"`
+	// Okna podsumowań - powiadomienia zbierane w jedną wiadomość zamiast osobnych
+	Digests []Digest `json:"digests"`
+}
+
+// Digest - powiadomienia kanału (opcjonalnie tylko dla wybranych adresatów i zdarzeń)
+// zbierane przez Window i wysyłane jako jedno podsumowanie. Pilne powiadomienia
+// (przekroczone SLA, akcje z "urgent") wysyłane są zawsze od razu.
+type Digest struct {
+	Channel string `json:"channel"`
+	// Adresaci objęci podsumowaniem; brak - wszyscy adresaci kanału
+	To []string `json:"to"`
+	// Zdarzenia objęte podsumowaniem; brak - wszystkie
+	Events []string `json:"events"`
+	// Długość okna, np. "5m"
+	Window string `json:"window"`
+}
+
+type User struct {
+	Email  string `json:"email"`
+	Locale string `json:"locale"`
+}
+
+type Rule struct {
+	Name string     `json:"name"`
+	When Conditions `json:"when"`
+	Send []Action   `json:"send"`
+	// Po dopasowaniu tej reguły kolejne nie są sprawdzane
+	Stop bool `json:"stop"`
+}
+
+// Conditions - wszystkie podane warunki muszą być spełnione; pusty warunek pasuje zawsze
+type Conditions struct {
+	Events    []string    `json:"events"`
+	Statuses  []string    `json:"statuses"`
+	Sources   []string    `json:"sources"`
+	MinAmount *float64    `json:"min_amount"`
+	MaxAmount *float64    `json:"max_amount"`
+	Time      *TimeWindow `json:"time"`
+}
+
+// TimeWindow - przedział godzin "HH:MM" (od włącznie, do wyłącznie); from > to oznacza
+// przedział przez północ (np. 22:00-06:00). Days ogranicza dni tygodnia (mon..sun).
+type TimeWindow struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Days []string `json:"days"`
+}
+
+// Action - dokąd wysłać powiadomienie po dopasowaniu reguły
+type Action struct {
+	Channel string `json:"channel"`
+	// Adresaci (e-mail); "@assignee" - pracownik przypisany do zamówienia (z sekcji "users")
+	To []string `json:"to"`
+	// Adres webhooka / Slacka zamiast domyślnego dla kanału (np. inny kanał Slacka)
+	URL string `json:"url"`
+	// Szablon zamiast domyślnego dla zdarzenia
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	// Wysyłka od razu, z pominięciem podsumowań
+	Urgent bool `json:"urgent"`
+}
+
+// Event - dane zdarzenia, na podstawie których dopasowywane są reguły
+type Event struct {
+	Name       string
+	Status     string
+	Source     string
+	Amount     float64
+	AssignedTo *int
+}
+
+// Delivery - pojedyncze powiadomienie wynikające z reguły
+type Delivery struct {
+	Rule     string
+	Channel  string
+	Template string
+	To       []string
+	URL      string
+	Locale   string
+	Urgent   bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+type compiledWindow struct {
+	from, to int // minuty od północy
+	days     map[time.Weekday]bool
+}
+
+func compileWindow(w *TimeWindow) (*compiledWindow, error) {
+	if w == nil {
+		return nil, nil
+	}
+	from, err := parseClock(w.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseClock(w.To)
+	if err != nil {
+		return nil, err
+	}
+	cw := &compiledWindow{from: from, to: to}
+	if len(w.Days) > 0 {
+		cw.days = make(map[time.Weekday]bool)
+		for _, day := range w.Days {
+			wd, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("nieznany dzień tygodnia %q (dozwolone: mon..sun)", day)
+			}
+			cw.days[wd] = true
+		}
+	}
+	return cw, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("nieprawidłowa godzina %q (oczekiwano HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains - dla przedziału przez północ dzień tygodnia dotyczy początku przedziału
+func (w *compiledWindow) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+
+	var inWindow bool
+	switch {
+	case w.from == w.to:
+		inWindow = true
+	case w.from < w.to:
+		inWindow = minute >= w.from && minute < w.to
+	default:
+		inWindow = minute >= w.from || minute < w.to
+		if minute < w.to {
+			day = (day + 6) % 7 // część po północy należy do poprzedniego dnia
+		}
+	}
+	return inWindow && (w.days == nil || w.days[day])
+}
+
+// Validate sprawdza poprawność przedziału
+func (w *TimeWindow) Validate() error {
+	_, err := compileWindow(w)
+	return err
+}
+
+// Contains sprawdza, czy chwila t (w strefie, w której podano godziny) mieści się w przedziale
+func (w *TimeWindow) Contains(t time.Time) bool {
+	cw, err := compileWindow(w)
+	return err == nil && cw != nil && cw.contains(t)
+}
+
+// End zwraca najbliższą chwilę po t, w której t nie mieści się już w przedziale
+// (t, jeśli jest poza przedziałem). Przedziały następujące po sobie są łączone.
+func (w *TimeWindow) End(t time.Time) time.Time {
+	cw, err := compileWindow(w)
+	if err != nil || cw == nil {
+		return t
+	}
+	// Najwyżej tydzień - przedział obejmujący cały tydzień nigdy się nie kończy
+	for i := 0; i < 8 && cw.contains(t); i++ {
+		minute := t.Hour()*60 + t.Minute()
+		day := t.Day()
+		switch {
+		case cw.from == cw.to:
+			t = time.Date(t.Year(), t.Month(), day+1, 0, 0, 0, 0, t.Location())
+			continue
+		case cw.from > cw.to && minute >= cw.from:
+			day++ // przedział kończy się następnego dnia
+		}
+		t = time.Date(t.Year(), t.Month(), day, cw.to/60, cw.to%60, 0, 0, t.Location())
+	}
+	return t
+}
+
+func (c Conditions) matches(ev Event, window *compiledWindow, now time.Time) bool {
+	if !matchesAny(c.Events, ev.Name) || !matchesAny(c.Statuses, ev.Status) || !matchesAny(c.Sources, ev.Source) {
+		return false
+	}
+	if c.MinAmount != nil && ev.Amount < *c.MinAmount {
+		return false
+	}
+	if c.MaxAmount != nil && ev.Amount > *c.MaxAmount {
+		return false
+	}
+	return window == nil || window.contains(now)
+}
+
+// covers sprawdza, czy podsumowanie obejmuje powiadomienie
+func (d Digest) covers(delivery Delivery, event string) bool {
+	if d.Channel != delivery.Channel || !matchesAny(d.Events, event) {
+		return false
+	}
+	if len(d.To) == 0 {
+		return true
+	}
+	if len(delivery.To) == 0 {
+		return false
+	}
+	for _, recipient := range delivery.To {
+		if !matchesAny(d.To, recipient) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRecipients zamienia "@assignee" na adres przypisanego pracownika; zwraca też
+// jego język (jeśli podany w sekcji "users")
+func (c *Config) resolveRecipients(to []string, ev Event) (recipients []string, locale string, ok bool) {
+	for _, recipient := range to {
+		if recipient != "@assignee" {
+			recipients = append(recipients, recipient)
+			continue
+		}
+		if ev.AssignedTo == nil {
+			continue
+		}
+		user, found := c.Users[strconv.Itoa(*ev.AssignedTo)]
+		if !found || user.Email == "" {
+			continue
+		}
+		recipients = append(recipients, user.Email)
+		locale = user.Locale
+	}
+	return recipients, locale, len(to) == 0 || len(recipients) > 0
+}
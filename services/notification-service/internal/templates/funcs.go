@@ -0,0 +1,75 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Nazwy statusów zamówień w obsługiwanych językach
+var statusNames = map[string]map[string]string{
+	"pl": {
+		"new":       "Nowe",
+		"confirmed": "Potwierdzone",
+		"shipped":   "Wysłane",
+		"delivered": "Dostarczone",
+		"cancelled": "Anulowane",
+	},
+	"en": {
+		"new":       "New",
+		"confirmed": "Confirmed",
+		"shipped":   "Shipped",
+		"delivered": "Delivered",
+		"cancelled": "Cancelled",
+	},
+}
+
+// funcMap - funkcje dostępne w szablonach, zależne od języka:
+//
+//	{{status .Status}}      - nazwa statusu w języku szablonu
+//	{{amount .TotalAmount}} - kwota z walutą ("1 299,99 zł" / "PLN 1,299.99")
+func funcMap(locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"status": func(status string) string {
+			return translateStatus(locale, status)
+		},
+		"amount": func(amount float64) string {
+			return formatAmount(locale, amount)
+		},
+	}
+}
+
+// translateStatus tłumaczy status na język odbiorcy (nieznany status bez zmian)
+func translateStatus(locale, status string) string {
+	if translated, ok := statusNames[locale][status]; ok {
+		return translated
+	}
+	return status
+}
+
+func formatAmount(locale string, amount float64) string {
+	s := fmt.Sprintf("%.2f", amount)
+	intPart, frac := s[:len(s)-3], s[len(s)-2:]
+	negative := strings.HasPrefix(intPart, "-")
+	intPart = strings.TrimPrefix(intPart, "-")
+
+	thousands, decimal := ",", "."
+	if locale == "pl" {
+		thousands, decimal = " ", ","
+	}
+
+	var groups []string
+	for len(intPart) > 3 {
+		groups = append([]string{intPart[len(intPart)-3:]}, groups...)
+		intPart = intPart[:len(intPart)-3]
+	}
+	groups = append([]string{intPart}, groups...)
+	number := strings.Join(groups, thousands) + decimal + frac
+	if negative {
+		number = "-" + number
+	}
+
+	if locale == "pl" {
+		return number + " zł"
+	}
+	return "PLN " + number
+}
@@ -0,0 +1,311 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Rodzaje plików szablonu
+const (
+	kindSubject = "subject"
+	kindText    = "txt"
+	kindHTML    = "html"
+)
+
+// Rendered - wynik renderowania szablonu dla jednego kanału
+type Rendered struct {
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// Info opisuje szablon dostępny w katalogu
+type Info struct {
+	Name     string   `json:"name"`
+	Locales  []string `json:"locales"`
+	Channels []string `json:"channels"` // kanały z własną wersją szablonu
+}
+
+type key struct {
+	locale  string
+	name    string
+	channel string // "" - wersja wspólna dla wszystkich kanałów
+	kind    string
+}
+
+type set struct {
+	text map[key]*texttemplate.Template
+	html map[key]*htmltemplate.Template
+}
+
+// Engine renderuje szablony powiadomień z plików w katalogu:
+//
+//	<katalog>/<język>/<nazwa>[.<kanał>].<subject|txt|html>.tmpl
+//
+// Wersja dla konkretnego kanału (np. order_new.slack.txt.tmpl) ma pierwszeństwo przed
+// wspólną (order_new.txt.tmpl). Brakujący szablon w języku odbiorcy jest brany z języka
+// domyślnego.
+type Engine struct {
+	dir           string
+	defaultLocale string
+
+	mu      sync.RWMutex
+	current *set
+}
+
+func NewEngine(dir, defaultLocale string) (*Engine, error) {
+	e := &Engine{dir: dir, defaultLocale: defaultLocale}
+	if err := e.Load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Engine) DefaultLocale() string {
+	return e.defaultLocale
+}
+
+// Load wczytuje (ponownie) wszystkie szablony. Przy błędzie dotychczasowe szablony
+// pozostają w użyciu.
+func (e *Engine) Load() error {
+	s, err := e.read()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.current = s
+	e.mu.Unlock()
+	return nil
+}
+
+// Fresh wczytuje szablony z dysku do osobnego silnika (np. dla podglądu zmian w plikach) -
+// szablony używane w wysyłce pozostają bez zmian
+func (e *Engine) Fresh() (*Engine, error) {
+	s, err := e.read()
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{dir: e.dir, defaultLocale: e.defaultLocale, current: s}, nil
+}
+
+func (e *Engine) read() (*set, error) {
+	locales, err := os.ReadDir(e.dir)
+	if err != nil {
+		return nil, fmt.Errorf("błąd odczytu katalogu szablonów: %w", err)
+	}
+
+	s := &set{
+		text: make(map[key]*texttemplate.Template),
+		html: make(map[key]*htmltemplate.Template),
+	}
+	for _, entry := range locales {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+		files, err := filepath.Glob(filepath.Join(e.dir, locale, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err := s.parse(locale, file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !s.hasLocale(e.defaultLocale) {
+		return nil, fmt.Errorf("brak szablonów dla domyślnego języka %s w %s", e.defaultLocale, e.dir)
+	}
+	return s, nil
+}
+
+func (s *set) parse(locale, file string) error {
+	base := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+	parts := strings.Split(base, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("nieprawidłowa nazwa pliku szablonu: %s", file)
+	}
+	k := key{locale: locale, name: parts[0], kind: parts[len(parts)-1]}
+	if len(parts) == 3 {
+		k.channel = parts[1]
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	funcs := funcMap(locale)
+	switch k.kind {
+	case kindSubject, kindText:
+		t, err := texttemplate.New(base).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("błąd szablonu %s: %w", file, err)
+		}
+		s.text[k] = t
+	case kindHTML:
+		t, err := htmltemplate.New(base).Funcs(funcs).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("błąd szablonu %s: %w", file, err)
+		}
+		s.html[k] = t
+	default:
+		return fmt.Errorf("nieznany rodzaj szablonu %q w %s (dozwolone: subject, txt, html)", k.kind, file)
+	}
+	return nil
+}
+
+func (s *set) hasLocale(locale string) bool {
+	for k := range s.text {
+		if k.locale == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Has sprawdza, czy istnieje szablon o podanej nazwie w języku domyślnym
+func (e *Engine) Has(name string) bool {
+	s := e.snapshot()
+	_, ok := s.text[key{locale: e.defaultLocale, name: name, kind: kindText}]
+	return ok
+}
+
+// Render renderuje szablon name dla kanału channel w języku locale
+func (e *Engine) Render(name, channel, locale string, data interface{}) (Rendered, error) {
+	s := e.snapshot()
+	locale = e.resolveLocale(s, locale)
+	result := Rendered{Locale: locale}
+
+	var err error
+	if result.Subject, err = e.execText(s, locale, name, channel, kindSubject, data); err != nil {
+		return result, err
+	}
+	if result.Text, err = e.execText(s, locale, name, channel, kindText, data); err != nil {
+		return result, err
+	}
+	result.Subject = strings.TrimSpace(result.Subject)
+
+	if t := e.lookupHTML(s, locale, name, channel); t != nil {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			return result, fmt.Errorf("błąd renderowania %s: %w", t.Name(), err)
+		}
+		result.HTML = b.String()
+	}
+	return result, nil
+}
+
+// List zwraca dostępne szablony
+func (e *Engine) List() []Info {
+	s := e.snapshot()
+	byName := make(map[string]*Info)
+	locales := make(map[string]map[string]bool)
+	channels := make(map[string]map[string]bool)
+
+	add := func(k key) {
+		if byName[k.name] == nil {
+			byName[k.name] = &Info{Name: k.name}
+			locales[k.name] = make(map[string]bool)
+			channels[k.name] = make(map[string]bool)
+		}
+		locales[k.name][k.locale] = true
+		if k.channel != "" {
+			channels[k.name][k.channel] = true
+		}
+	}
+	for k := range s.text {
+		add(k)
+	}
+	for k := range s.html {
+		add(k)
+	}
+
+	result := make([]Info, 0, len(byName))
+	for name, info := range byName {
+		info.Locales = sortedKeys(locales[name])
+		info.Channels = sortedKeys(channels[name])
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (e *Engine) snapshot() *set {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.current
+}
+
+// resolveLocale dopasowuje język odbiorcy do dostępnych szablonów ("en-US" -> "en")
+func (e *Engine) resolveLocale(s *set, locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale != "" && s.hasLocale(locale) {
+		return locale
+	}
+	if i := strings.IndexAny(locale, "-_"); i > 0 && s.hasLocale(locale[:i]) {
+		return locale[:i]
+	}
+	return e.defaultLocale
+}
+
+func (e *Engine) execText(s *set, locale, name, channel, kind string, data interface{}) (string, error) {
+	var t *texttemplate.Template
+	for _, k := range e.candidates(locale, name, channel, kind) {
+		if t = s.text[k]; t != nil {
+			break
+		}
+	}
+	if t == nil {
+		return "", fmt.Errorf("brak szablonu %s.%s (język %s)", name, kind, locale)
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("błąd renderowania %s: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+func (e *Engine) lookupHTML(s *set, locale, name, channel string) *htmltemplate.Template {
+	for _, k := range e.candidates(locale, name, channel, kindHTML) {
+		if t := s.html[k]; t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// candidates - kolejność wyszukiwania: kanał w języku odbiorcy, wersja wspólna w języku
+// odbiorcy, następnie to samo w języku domyślnym
+func (e *Engine) candidates(locale, name, channel, kind string) []key {
+	keys := []key{
+		{locale: locale, name: name, channel: channel, kind: kind},
+		{locale: locale, name: name, kind: kind},
+	}
+	if locale != e.defaultLocale {
+		keys = append(keys,
+			key{locale: e.defaultLocale, name: name, channel: channel, kind: kind},
+			key{locale: e.defaultLocale, name: name, kind: kind},
+		)
+	}
+	return keys
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RoleAdmin    = "admin"
+	RoleEmployee = "employee"
+)
+
+// CurrentUser - użytkownik z tokenu JWT
+type CurrentUser struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AuthMiddleware weryfikuje tokeny JWT wystawione przez auth-service (wspólny JWT_SECRET)
+type AuthMiddleware struct {
+	secret []byte
+}
+
+func NewAuthMiddleware() *AuthMiddleware {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "secret-key"
+	}
+	return &AuthMiddleware{secret: []byte(secret)}
+}
+
+func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
+			return
+		}
+
+		tokenParts := strings.Split(authHeader, " ")
+		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
+			return
+		}
+
+		user, err := m.ValidateToken(tokenParts[1])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Zapisanie w kontekście
+		c.Set("user_id", user.ID)
+		c.Set("email", user.Email)
+		c.Set("role", user.Role)
+
+		c.Next()
+	}
+}
+
+func (m *AuthMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowedRole := range allowedRoles {
+			if role == allowedRole {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":          "insufficient_permissions",
+			"required_roles": allowedRoles,
+			"user_role":      role,
+		})
+	}
+}
+
+func (m *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
+	return m.RequireRole(RoleAdmin)
+}
+
+// ValidateToken sprawdza podpis i ważność tokenu oraz zwraca dane użytkownika
+func (m *AuthMiddleware) ValidateToken(tokenString string) (*CurrentUser, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return m.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+
+	// Liczby w claims JSON są dekodowane jako float64
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid user_id claim: %T", claims["user_id"])
+	}
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+
+	return &CurrentUser{ID: int(userID), Email: email, Role: role}, nil
+}
+
+func GetCurrentUser(c *gin.Context) (*CurrentUser, error) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil, jwt.ErrTokenNotValidYet
+	}
+
+	id, ok := userID.(int)
+	if !ok {
+		return nil, fmt.Errorf("invalid user type: expected int, got %T", userID)
+	}
+
+	return &CurrentUser{
+		ID:    id,
+		Email: c.GetString("email"),
+		Role:  c.GetString("role"),
+	}, nil
+}
@@ -0,0 +1,89 @@
+-- Historia powiadomień - jeden wiersz na wiadomość (zdarzenie, kanał, szablon, adresaci);
+-- kolejne próby wysyłki (ponowienia, ręczne ponowne wysłanie) zwiększają attempts
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    event_id VARCHAR(64) NOT NULL,
+    order_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    template VARCHAR(100) NOT NULL,
+    locale VARCHAR(8) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}', -- puste = domyślni adresaci kanału
+    url TEXT NOT NULL DEFAULT '',            -- adres webhooka z reguły (puste = domyślny)
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL DEFAULT '',
+    html TEXT NOT NULL DEFAULT '',
+    content_hash CHAR(64) NOT NULL,           -- SHA-256 treści (temat, tekst, HTML)
+    payload JSONB NOT NULL,                   -- zdarzenie (treść webhooka przy ponownym wysłaniu)
+    status VARCHAR(20) NOT NULL,              -- 'sent', 'failed'
+    last_error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, channel, template, recipients, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_order ON notifications (order_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_created ON notifications (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_failed ON notifications (created_at DESC) WHERE status = 'failed';
+
+-- Przetworzone zdarzenia (deduplikacja ponownie dostarczonych wiadomości); wpisy
+-- po expires_at są usuwane okresowo
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(64) PRIMARY KEY,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_expires ON processed_events (expires_at);
+
+-- Powiadomienia oczekujące na podsumowanie; grupa (digest_key) jest wysyłana, gdy minie
+-- flush_at jej najstarszego wpisu
+CREATE TABLE IF NOT EXISTS digest_items (
+    id SERIAL PRIMARY KEY,
+    digest_key CHAR(64) NOT NULL,  -- skrót: kanał, adresaci, adres, język
+    channel VARCHAR(20) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    url TEXT NOT NULL DEFAULT '',
+    locale VARCHAR(8) NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    flush_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (digest_key, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_items_key ON digest_items (digest_key, flush_at);
+
+-- Preferencje powiadomień pracowników (ID użytkownika z auth-service)
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id INTEGER PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    locale VARCHAR(8) NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Warsaw',
+    quiet_hours JSONB,                   -- {"from": "22:00", "to": "07:00", "days": [...], "allow_urgent": false}
+    events JSONB NOT NULL DEFAULT '{}',  -- zdarzenie -> kanały, np. {"order.created": ["smtp"]}
+    slack_url TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_preferences_email ON user_preferences (LOWER(email));
+
+-- Powiadomienia wstrzymane na czas ciszy nocnej odbiorcy
+CREATE TABLE IF NOT EXISTS deferred_notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    template VARCHAR(100) NOT NULL,
+    locale VARCHAR(8) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    url TEXT NOT NULL DEFAULT '',
+    event_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    deliver_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, channel, template, recipients, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_deferred_notifications_due ON deferred_notifications (deliver_at);
@@ -13,17 +13,21 @@ type DB struct {
 	*sql.DB
 }
 
-func NewConnection() (*DB, error) {
+// ConnectionString zwraca parametry połączenia ze zmiennych środowiskowych
+// (używane także przez osobne połączenia, np. LISTEN/NOTIFY)
+func ConnectionString() string {
 	host := getEnv("DB_HOST", "localhost")
 	port := getEnv("DB_PORT", "5432")
 	user := getEnv("DB_USER", "postgres")
 	password := getEnv("DB_PASSWORD", "password123")
 	dbname := getEnv("DB_NAME", "orders_management")
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
+func NewConnection() (*DB, error) {
+	db, err := sql.Open("postgres", ConnectionString())
 	if err != nil {
 		return nil, fmt.Errorf("BŁĄD: nie można otworzyć bazy danych: %v", err)
 	}
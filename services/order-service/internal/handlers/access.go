@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/users"
+	"github.com/iDos27/order-management/order-service/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeOrderAccess sprawdza czy zalogowany użytkownik może zobaczyć zamówienie:
+// admin i pracownik - wszystkie zamówienia, klient - tylko złożone na swój adres e-mail.
+// W razie braku dostępu odpowiada odpowiednim kodem i zwraca false.
+func authorizeOrderAccess(c *gin.Context, db *database.DB, orderID int) bool {
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return false
+	}
+
+	var customerEmail string
+	err = db.QueryRow(`SELECT customer_email FROM orders WHERE id = $1`, orderID).Scan(&customerEmail)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return false
+	}
+
+	if isStaff(currentUser.Role) {
+		return true
+	}
+	if currentUser.Role == models.RoleCustomer && currentUser.Email == customerEmail {
+		return true
+	}
+
+	// 404 zamiast 403 - klient nie dowiaduje się o istnieniu cudzych zamówień
+	c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	return false
+}
+
+func isStaff(role string) bool {
+	return role == models.RoleAdmin || role == models.RoleEmployee
+}
+
+// checkAssignee sprawdza, czy zamówienie można przypisać użytkownikowi userID (nil - bez
+// przypisania): musi istnieć w auth-service i mieć rolę admin lub employee.
+// W przeciwnym razie odpowiada odpowiednim kodem i zwraca false.
+func checkAssignee(c *gin.Context, directory *users.Directory, userID *int) bool {
+	if userID == nil {
+		return true
+	}
+	staff, err := directory.IsStaff(*userID, c.GetHeader("Authorization"))
+	if err != nil {
+		log.Printf("Błąd sprawdzania użytkownika %d w auth-service: %v", *userID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify assigned user"})
+		return false
+	}
+	if !staff {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assigned user must be an existing admin or employee"})
+		return false
+	}
+	return true
+}
+
+// actorFromContext zwraca użytkownika z tokenu JWT jako autora zmiany (nil, jeśli brak)
+func actorFromContext(c *gin.Context) *models.CurrentUser {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/storage"
+	"github.com/iDos27/order-management/order-service/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Typy plików dozwolone jako załączniki (faktury, zdjęcia, skany dokumentów dostawy)
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+}
+
+type AttachmentHandler struct {
+	db      *database.DB
+	storage storage.Storage
+	maxSize int64
+}
+
+func NewAttachmentHandler(db *database.DB, store storage.Storage, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{db: db, storage: store, maxSize: maxSize}
+}
+
+// GET /api/orders/:id/attachments - Lista załączników zamówienia
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	orderID, ok := parseIDParam(c, "id")
+	if !ok || !authorizeOrderAccess(c, h.db, orderID) {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, order_id, file_name, content_type, size_bytes, storage_key, uploaded_by, created_at
+		FROM order_attachments
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	defer rows.Close()
+
+	attachments := []models.OrderAttachment{}
+	for rows.Next() {
+		var a models.OrderAttachment
+		if err := rows.Scan(&a.ID, &a.OrderID, &a.FileName, &a.ContentType, &a.SizeBytes,
+			&a.StorageKey, &a.UploadedBy, &a.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan attachment"})
+			return
+		}
+		attachments = append(attachments, a)
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// POST /api/orders/:id/attachments - Upload załącznika (multipart, pole "file")
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	orderID, ok := parseIDParam(c, "id")
+	if !ok || !authorizeOrderAccess(c, h.db, orderID) {
+		return
+	}
+
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+
+	// Limit całego żądania (plik + narzut multipart), dokładny rozmiar pliku sprawdzamy niżej
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field"})
+		return
+	}
+	if fileHeader.Size > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":     "File too large",
+			"max_bytes": h.maxSize,
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	// Typ pliku rozpoznajemy po zawartości, nie ufamy nagłówkowi Content-Type klienta
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !allowedAttachmentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":        "Unsupported file type",
+			"content_type": contentType,
+		})
+		return
+	}
+
+	key, err := attachmentKey(orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+	size, err := h.storage.Save(key, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		log.Printf("Błąd zapisu załącznika zamówienia #%d: %v", orderID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	attachment := models.OrderAttachment{
+		OrderID:     orderID,
+		FileName:    sanitizeFileName(fileHeader.Filename),
+		ContentType: contentType,
+		SizeBytes:   size,
+		StorageKey:  key,
+		UploadedBy:  currentUser.ID,
+	}
+	err = h.db.QueryRow(`
+		INSERT INTO order_attachments (order_id, file_name, content_type, size_bytes, storage_key, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, attachment.OrderID, attachment.FileName, attachment.ContentType, attachment.SizeBytes,
+		attachment.StorageKey, attachment.UploadedBy).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		// Bez metadanych plik byłby nieosiągalny - sprzątamy
+		if delErr := h.storage.Delete(key); delErr != nil {
+			log.Printf("Błąd usuwania osieroconego pliku %s: %v", key, delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// GET /api/orders/:id/attachments/:attachmentId - Pobranie załącznika
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	attachment, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+
+	reader, err := h.storage.Open(attachment.StorageKey)
+	if err != nil {
+		log.Printf("Błąd odczytu załącznika #%d: %v", attachment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer reader.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+	c.DataFromReader(http.StatusOK, attachment.SizeBytes, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition":    disposition,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// DELETE /api/orders/:id/attachments/:attachmentId - Usunięcie załącznika
+// Klient może usuwać tylko załączniki dodane przez siebie
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	attachment, ok := h.loadAttachment(c)
+	if !ok {
+		return
+	}
+
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+	if !isStaff(currentUser.Role) && attachment.UploadedBy != currentUser.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_permissions"})
+		return
+	}
+
+	if _, err := h.db.Exec(`DELETE FROM order_attachments WHERE id = $1`, attachment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	if err := h.storage.Delete(attachment.StorageKey); err != nil && err != storage.ErrNotFound {
+		log.Printf("Błąd usuwania pliku załącznika #%d: %v", attachment.ID, err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// loadAttachment sprawdza dostęp do zamówienia i pobiera metadane załącznika z parametrów ścieżki
+func (h *AttachmentHandler) loadAttachment(c *gin.Context) (models.OrderAttachment, bool) {
+	var a models.OrderAttachment
+	orderID, ok := parseIDParam(c, "id")
+	if !ok {
+		return a, false
+	}
+	attachmentID, ok := parseIDParam(c, "attachmentId")
+	if !ok || !authorizeOrderAccess(c, h.db, orderID) {
+		return a, false
+	}
+
+	err := h.db.QueryRow(`
+		SELECT id, order_id, file_name, content_type, size_bytes, storage_key, uploaded_by, created_at
+		FROM order_attachments
+		WHERE id = $1 AND order_id = $2
+	`, attachmentID, orderID).Scan(&a.ID, &a.OrderID, &a.FileName, &a.ContentType, &a.SizeBytes,
+		&a.StorageKey, &a.UploadedBy, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return a, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return a, false
+	}
+	return a, true
+}
+
+// attachmentKey generuje losowy klucz - nazwa pliku od klienta nie trafia na dysk
+func attachmentKey(orderID int) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("orders/%d/%s", orderID, hex.EncodeToString(buf)), nil
+}
+
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
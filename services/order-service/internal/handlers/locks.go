@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/websocket"
+	"github.com/iDos27/order-management/order-service/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type LockHandler struct {
+	db  *database.DB
+	hub *websocket.Hub
+	ttl time.Duration
+}
+
+func NewLockHandler(db *database.DB, hub *websocket.Hub, ttl time.Duration) *LockHandler {
+	return &LockHandler{db: db, hub: hub, ttl: ttl}
+}
+
+const lockColumns = `order_id, user_id, user_email, acquired_at, expires_at`
+
+func scanLock(row rowScanner) (models.OrderLock, error) {
+	var lock models.OrderLock
+	err := row.Scan(&lock.OrderID, &lock.UserID, &lock.UserEmail, &lock.AcquiredAt, &lock.ExpiresAt)
+	return lock, err
+}
+
+// GET /api/orders/:id/lock - Aktualna blokada edycji zamówienia
+func (h *LockHandler) GetLock(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	lock, err := scanLock(h.db.QueryRow(`
+		SELECT `+lockColumns+` FROM order_locks WHERE order_id = $1 AND expires_at > NOW()
+	`, id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order is not locked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, lock)
+}
+
+// POST /api/orders/:id/lock - Założenie lub odnowienie blokady (ważnej przez ORDER_LOCK_TTL)
+func (h *LockHandler) AcquireLock(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+
+	// Blokadę przejmujemy tylko, jeśli należy do nas albo wygasła
+	lock, err := scanLock(h.db.QueryRow(`
+		INSERT INTO order_locks (order_id, user_id, user_email, acquired_at, expires_at)
+		SELECT id, $2, $3, NOW(), NOW() + make_interval(secs => $4) FROM orders WHERE id = $1
+		ON CONFLICT (order_id) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+		    user_email = EXCLUDED.user_email,
+		    acquired_at = CASE WHEN order_locks.user_id = EXCLUDED.user_id AND order_locks.expires_at > NOW()
+		                       THEN order_locks.acquired_at ELSE EXCLUDED.acquired_at END,
+		    expires_at = EXCLUDED.expires_at
+		WHERE order_locks.user_id = EXCLUDED.user_id OR order_locks.expires_at <= NOW()
+		RETURNING `+lockColumns, id, currentUser.ID, currentUser.Email, h.ttl.Seconds()))
+	if err == sql.ErrNoRows {
+		// Brak zamówienia albo blokada innego użytkownika
+		held, err := scanLock(h.db.QueryRow(`SELECT `+lockColumns+` FROM order_locks WHERE order_id = $1`, id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		c.JSON(http.StatusLocked, gin.H{"error": "Order is locked by another user", "lock": held})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock order"})
+		return
+	}
+
+	h.broadcast(websocket.TypeOrderLocked, id, &lock, currentUser)
+	c.JSON(http.StatusOK, lock)
+}
+
+// DELETE /api/orders/:id/lock - Zwolnienie blokady (własnej; admin może zwolnić każdą)
+func (h *LockHandler) ReleaseLock(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+
+	var holderID int
+	err = h.db.QueryRow(`
+		DELETE FROM order_locks
+		WHERE order_id = $1 AND (user_id = $2 OR $3 OR expires_at <= NOW())
+		RETURNING user_id
+	`, id, currentUser.ID, currentUser.Role == models.RoleAdmin).Scan(&holderID)
+	if err == sql.ErrNoRows {
+		var exists bool
+		h.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM order_locks WHERE order_id = $1)`, id).Scan(&exists)
+		if exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Lock is held by another user"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order is not locked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release lock"})
+		return
+	}
+
+	h.broadcast(websocket.TypeOrderUnlocked, id, nil, currentUser)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *LockHandler) broadcast(messageType string, orderID int, lock *models.OrderLock, actor *models.CurrentUser) {
+	ref := websocket.OrderRef{ID: orderID}
+	h.db.QueryRow(`SELECT status, source, assigned_to FROM orders WHERE id = $1`, orderID).
+		Scan(&ref.Status, &ref.Source, &ref.AssignedTo)
+
+	h.hub.BroadcastEvent(messageType, websocket.OrderLockMessage{
+		OrderId: orderID,
+		Lock:    lock,
+		Actor:   actor,
+	}, ref)
+}
+
+// checkOrderLock odpowiada 423, jeśli zamówienie ma ważną blokadę innego użytkownika
+func checkOrderLock(c *gin.Context, db *database.DB, orderID int) bool {
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return false
+	}
+
+	lock, err := scanLock(db.QueryRow(`
+		SELECT `+lockColumns+` FROM order_locks
+		WHERE order_id = $1 AND expires_at > NOW() AND user_id <> $2
+	`, orderID, currentUser.ID))
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check order lock"})
+		return false
+	}
+
+	c.JSON(http.StatusLocked, gin.H{"error": "Order is locked by another user", "lock": lock})
+	return false
+}
+
+// lockedByOthers zwraca ważne blokady innych użytkowników dla podanych zamówień
+func lockedByOthers(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, orderIDs []int, userID int) (map[int]models.OrderLock, error) {
+	rows, err := q.Query(`
+		SELECT `+lockColumns+` FROM order_locks
+		WHERE order_id = ANY($1) AND expires_at > NOW() AND user_id <> $2
+	`, pq.Array(orderIDs), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := make(map[int]models.OrderLock)
+	for rows.Next() {
+		lock, err := scanLock(rows)
+		if err != nil {
+			return nil, err
+		}
+		locks[lock.OrderID] = lock
+	}
+	return locks, rows.Err()
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/websocket"
+	"github.com/iDos27/order-management/order-service/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxNoteLength = 5000
+
+// GET /api/orders/:id/notes - Notatki do zamówienia (od najstarszej)
+func (h *OrderHandler) GetOrderNotes(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, order_id, author_id, author_email, body, created_at
+		FROM order_notes
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+	defer rows.Close()
+
+	notes := []models.OrderNote{}
+	for rows.Next() {
+		var note models.OrderNote
+		if err := rows.Scan(&note.ID, &note.OrderID, &note.AuthorID, &note.AuthorEmail,
+			&note.Body, &note.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan note"})
+			return
+		}
+		notes = append(notes, note)
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// POST /api/orders/:id/notes - Dodanie notatki (autor z tokenu JWT)
+func (h *OrderHandler) AddOrderNote(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" || len(body) > maxNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Note body must be between 1 and 5000 characters"})
+		return
+	}
+
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+
+	note := models.OrderNote{
+		OrderID:     id,
+		AuthorID:    currentUser.ID,
+		AuthorEmail: currentUser.Email,
+		Body:        body,
+	}
+	// INSERT ... SELECT - brak zamówienia daje sql.ErrNoRows zamiast błędu klucza obcego;
+	// przy okazji pobieramy dane zamówienia potrzebne do dopasowania subskrypcji WebSocket
+	ref := websocket.OrderRef{ID: id}
+	err = h.db.QueryRow(`
+		WITH o AS (
+			SELECT id, status, source, assigned_to FROM orders WHERE id = $1
+		), n AS (
+			INSERT INTO order_notes (order_id, author_id, author_email, body)
+			SELECT id, $2, $3, $4 FROM o
+			RETURNING id, created_at
+		)
+		SELECT n.id, n.created_at, o.status, o.source, o.assigned_to FROM n, o
+	`, note.OrderID, note.AuthorID, note.AuthorEmail, note.Body).
+		Scan(&note.ID, &note.CreatedAt, &ref.Status, &ref.Source, &ref.AssignedTo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add note"})
+		return
+	}
+
+	h.hub.BroadcastEvent(websocket.TypeNoteAdded, websocket.NoteAddedMessage{
+		OrderId: note.OrderID,
+		Note:    note,
+		Actor:   currentUser,
+	}, ref)
+
+	c.JSON(http.StatusCreated, note)
+}
@@ -1,35 +1,76 @@
 package handlers
 
 import (
+	"database/sql"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/iDos27/order-management/order-service/internal/database"
 	"github.com/iDos27/order-management/order-service/internal/models"
 	"github.com/iDos27/order-management/order-service/internal/publisher"
+	"github.com/iDos27/order-management/order-service/internal/storage"
+	"github.com/iDos27/order-management/order-service/internal/users"
+	"github.com/iDos27/order-management/order-service/internal/webhooks"
 	"github.com/iDos27/order-management/order-service/internal/websocket"
+	"github.com/iDos27/order-management/order-service/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 type OrderHandler struct {
 	db        *database.DB
 	hub       *websocket.Hub
 	publisher *publisher.Publisher
+	webhooks  *webhooks.Dispatcher
+	storage   storage.Storage
+	users     *users.Directory
 }
 
-func NewOrderHandler(db *database.DB, hub *websocket.Hub, pub *publisher.Publisher) *OrderHandler {
-	return &OrderHandler{db: db, hub: hub, publisher: pub}
+func NewOrderHandler(db *database.DB, hub *websocket.Hub, pub *publisher.Publisher, wh *webhooks.Dispatcher, store storage.Storage, directory *users.Directory) *OrderHandler {
+	return &OrderHandler{db: db, hub: hub, publisher: pub, webhooks: wh, storage: store, users: directory}
 }
 
-// GET /api/orders - Lista wszystkich zamówień (admin)
+// Kolumny zamówienia w kolejności oczekiwanej przez scanOrder
+const orderColumns = `id, customer_name, customer_email, customer_locale, source, status, total_amount, tags, assigned_to, created_at, updated_at`
+
+func scanOrder(row rowScanner) (models.Order, error) {
+	var order models.Order
+	err := row.Scan(&order.ID, &order.CustomerName, &order.CustomerEmail, &order.CustomerLocale, &order.Source,
+		&order.Status, &order.TotalAmount, pq.Array(&order.Tags), &order.AssignedTo,
+		&order.CreatedAt, &order.UpdatedAt)
+	order.Tags = nonNilStrings(order.Tags)
+	return order, err
+}
+
+// GET /api/orders - Lista wszystkich zamówień (admin, employee)
+// Filtry: ?tag=a&tag=b (zamówienia posiadające wszystkie tagi), ?assigned_to=<id użytkownika>
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
-	rows, err := h.db.Query(`
-        SELECT id, customer_name, customer_email, source, status, total_amount, created_at, updated_at 
+	query := `
+        SELECT ` + orderColumns + `
         FROM orders 
-        ORDER BY created_at DESC
-    `)
+        WHERE 1=1`
+	var args []interface{}
+
+	if tags := normalizeTags(c.QueryArray("tag")); len(tags) > 0 {
+		args = append(args, pq.Array(tags))
+		query += " AND tags @> $" + strconv.Itoa(len(args))
+	}
+	if assigned := c.Query("assigned_to"); assigned != "" {
+		userID, err := strconv.Atoi(assigned)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assigned_to value"})
+			return
+		}
+		args = append(args, userID)
+		query += " AND assigned_to = $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
@@ -38,9 +79,7 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 
 	var orders []models.Order
 	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(&order.ID, &order.CustomerName, &order.CustomerEmail, &order.Source,
-			&order.Status, &order.TotalAmount, &order.CreatedAt, &order.UpdatedAt)
+		order, err := scanOrder(rows)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan order"})
 			return
@@ -51,7 +90,7 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
-// GET /api/orders/:id - Pojedycze zamówienie (klient)
+// GET /api/orders/:id - Pojedycze zamówienie (klient - tylko własne)
 func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -59,13 +98,14 @@ func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
 		return
 	}
+	if !authorizeOrderAccess(c, h.db, id) {
+		return
+	}
 
-	var order models.Order
-	err = h.db.QueryRow(`
-        SELECT id, customer_name, customer_email, source, status, total_amount, created_at, updated_at 
+	order, err := scanOrder(h.db.QueryRow(`
+        SELECT `+orderColumns+`
         FROM orders WHERE id = $1
-    `, id).Scan(&order.ID, &order.CustomerName, &order.CustomerEmail, &order.Source,
-		&order.Status, &order.TotalAmount, &order.CreatedAt, &order.UpdatedAt)
+    `, id))
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
@@ -75,23 +115,51 @@ func (h *OrderHandler) GetOrderByID(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
-// POST /api/orders - Tworzenie nowego zamówienia
+// POST /api/orders - Tworzenie nowego zamówienia (tagi i przypisanie - tylko pracownicy)
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var order models.Order
 	if err := c.ShouldBindJSON(&order); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
 		return
 	}
+
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+	order.Tags = normalizeTags(order.Tags)
+	if !isStaff(currentUser.Role) && (len(order.Tags) > 0 || order.AssignedTo != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only staff can set tags or assignment"})
+		return
+	}
+	for _, tag := range order.Tags {
+		if len(tag) > maxTagLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag too long"})
+			return
+		}
+	}
+	if !checkAssignee(c, h.users, order.AssignedTo) {
+		return
+	}
 	if order.Source == "" {
 		order.Source = models.SourceWebsite
 	}
+	if order.CustomerLocale == "" {
+		order.CustomerLocale = models.DefaultLocale
+	}
+	if !models.IsSupportedLocale(order.CustomerLocale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported customer locale"})
+		return
+	}
 
 	order.Status = models.StatusNew // Ustawiamy domyślny status
-	err := h.db.QueryRow(`
-		INSERT INTO orders (customer_name, customer_email, source, status, total_amount, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+	err = h.db.QueryRow(`
+		INSERT INTO orders (customer_name, customer_email, customer_locale, source, status, total_amount, tags, assigned_to, created_at, updated_at, status_changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW(), NOW())
 		RETURNING id, created_at, updated_at
-		`, order.CustomerName, order.CustomerEmail, order.Source, order.Status, order.TotalAmount).
+		`, order.CustomerName, order.CustomerEmail, order.CustomerLocale, order.Source, order.Status, order.TotalAmount,
+		pq.Array(order.Tags), order.AssignedTo).
 		Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
@@ -100,16 +168,24 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	// WebSocket powiadomienie o nowym zamówieniu
-	h.hub.BroadcastOrderUpdate(order.ID, string(order.Status), "system")
+	h.hub.BroadcastOrderEvent(websocket.TypeOrderCreated, websocket.OrderEventMessage{
+		Order: order,
+		Actor: actorFromContext(c),
+	})
 
 	// RabbitMQ powiadomienie
 	if h.publisher != nil {
 		notification := publisher.OrderNotification{
-			OrderID:      order.ID,
-			CustomerName: order.CustomerName,
-			Status:       string(order.Status),
-			TotalAmount:  order.TotalAmount,
-			Timestamp:    time.Now(),
+			Event:          models.EventOrderCreated,
+			OrderID:        order.ID,
+			CustomerName:   order.CustomerName,
+			CustomerEmail:  order.CustomerEmail,
+			CustomerLocale: order.CustomerLocale,
+			Source:         string(order.Source),
+			AssignedTo:     order.AssignedTo,
+			Status:         string(order.Status),
+			TotalAmount:    order.TotalAmount,
+			Timestamp:      time.Now(),
 		}
 		if err := h.publisher.PublishOrderNotification(notification); err != nil {
 			// Logujemy błąd, ale nie przerywamy requestu
@@ -117,6 +193,15 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		}
 	}
 
+	// Webhooki partnerów
+	h.webhooks.DispatchOrderEvent(models.EventOrderCreated, models.OrderEventData{
+		OrderID:      order.ID,
+		CustomerName: order.CustomerName,
+		Source:       order.Source,
+		Status:       order.Status,
+		TotalAmount:  order.TotalAmount,
+	})
+
 	c.JSON(http.StatusCreated, order)
 }
 
@@ -139,57 +224,442 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	}
 
 	// Walidacja
-	validStatuses := []string{"new", "confirmed", "shipped", "delivered", "cancelled"}
-	isValid := false
-	for _, status := range validStatuses {
-		if statusUpdate.Status == status {
-			isValid = true
-			break
-		}
-	}
-	if !isValid {
+	newStatus := models.OrderStatus(statusUpdate.Status)
+	if !newStatus.IsValid() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status value"})
 		return
 	}
+	if !checkOrderLock(c, h.db, id) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Blokujemy zamówienie do końca transakcji - równoległa zmiana statusu poczeka i sprawdzi
+	// przejście względem statusu ustawionego przez pierwszą
+	var order models.Order
+	err = tx.QueryRow(`
+		SELECT id, customer_name, source, status, total_amount, assigned_to FROM orders WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&order.ID, &order.CustomerName, &order.Source, &order.Status, &order.TotalAmount, &order.AssignedTo)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+	if !order.Status.CanTransitionTo(newStatus) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "Status transition not allowed",
+			"current_status": order.Status,
+		})
+		return
+	}
 
 	// Aktualizacja statusu w bazie
-	_, err = h.db.Exec(`
+	updated, err := scanOrder(tx.QueryRow(`
         UPDATE orders 
-        SET status = $1, updated_at = CURRENT_TIMESTAMP 
+        SET status = $1, updated_at = CURRENT_TIMESTAMP, status_changed_at = CURRENT_TIMESTAMP, sla_breached_at = NULL 
         WHERE id = $2
-    `, statusUpdate.Status, id)
+        RETURNING `+orderColumns, statusUpdate.Status, id))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit status change"})
+		return
+	}
 
 	// Powiadomienie przez WebSocket
-	h.hub.BroadcastOrderUpdate(id, statusUpdate.Status, "admin")
+	h.hub.BroadcastOrderEvent(websocket.TypeOrderUpdated, websocket.OrderEventMessage{
+		Order:          updated,
+		PreviousStatus: order.Status,
+		Changes:        []string{websocket.ChangeStatus},
+		Actor:          actorFromContext(c),
+	})
 
 	// RabbitMQ powiadomienie przy zmianie statusu
 	if h.publisher != nil {
-		// Pobieramy dane zamówienia do powiadomienia
-		var order models.Order
-		err = h.db.QueryRow(`
-			SELECT customer_name, total_amount FROM orders WHERE id = $1
-		`, id).Scan(&order.CustomerName, &order.TotalAmount)
-
-		if err == nil {
-			notification := publisher.OrderNotification{
-				OrderID:      id,
-				CustomerName: order.CustomerName,
-				Status:       statusUpdate.Status,
-				TotalAmount:  order.TotalAmount,
-				Timestamp:    time.Now(),
-			}
-			h.publisher.PublishOrderNotification(notification)
+		notification := publisher.OrderNotification{
+			Event:          models.EventOrderStatusChanged,
+			OrderID:        id,
+			CustomerName:   updated.CustomerName,
+			CustomerEmail:  updated.CustomerEmail,
+			CustomerLocale: updated.CustomerLocale,
+			Source:         string(updated.Source),
+			AssignedTo:     updated.AssignedTo,
+			Status:         statusUpdate.Status,
+			PreviousStatus: string(order.Status),
+			TotalAmount:    order.TotalAmount,
+			Timestamp:      time.Now(),
 		}
+		h.publisher.PublishOrderNotification(notification)
 	}
 
+	// Webhooki partnerów
+	h.webhooks.DispatchOrderEvent(models.EventOrderStatusChanged, models.OrderEventData{
+		OrderID:        id,
+		CustomerName:   order.CustomerName,
+		Source:         order.Source,
+		Status:         newStatus,
+		PreviousStatus: order.Status,
+		TotalAmount:    order.TotalAmount,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":    "Order status updated successfully",
 		"order_id":   id,
 		"new_status": statusUpdate.Status,
 	})
 }
+
+// POST /api/orders/bulk/status - Zbiorcza zmiana statusu zamówień
+func (h *OrderHandler) BulkUpdateOrderStatus(c *gin.Context) {
+	var req models.BulkStatusUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+	if !req.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status value"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Blokujemy zamówienia na czas transakcji, aby równoległe zmiany nie ominęły reguł przejść
+	rows, err := tx.Query(`
+		SELECT id, customer_name, source, status, total_amount, assigned_to
+		FROM orders WHERE id = ANY($1)
+		FOR UPDATE
+	`, pq.Array(req.OrderIDs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	found := make(map[int]models.Order)
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Source, &order.Status, &order.TotalAmount, &order.AssignedTo); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan order"})
+			return
+		}
+		found[order.ID] = order
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	// Zamówienia z blokadą edycji innego użytkownika są pomijane
+	actor := actorFromContext(c)
+	if actor == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+	locks, err := lockedByOthers(tx, req.OrderIDs, actor.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check order locks"})
+		return
+	}
+
+	results := make([]models.BulkStatusResult, 0, len(req.OrderIDs))
+	var updated []models.Order
+	var events []websocket.OrderEventMessage
+	seen := make(map[int]bool)
+	for _, id := range req.OrderIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		result := models.BulkStatusResult{OrderID: id}
+		order, ok := found[id]
+		switch {
+		case !ok:
+			result.Error = "Order not found"
+		case !order.Status.CanTransitionTo(req.Status):
+			result.PreviousStatus = order.Status
+			result.Error = "Status transition not allowed"
+		case locks[id].UserID != 0:
+			result.PreviousStatus = order.Status
+			result.Error = "Order is locked by another user"
+		default:
+			result.PreviousStatus = order.Status
+			snapshot, err := scanOrder(tx.QueryRow(`
+				UPDATE orders
+				SET status = $1, updated_at = CURRENT_TIMESTAMP, status_changed_at = CURRENT_TIMESTAMP, sla_breached_at = NULL
+				WHERE id = $2
+				RETURNING `+orderColumns, req.Status, id))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+				return
+			}
+			result.Success = true
+			updated = append(updated, order)
+			events = append(events, websocket.OrderEventMessage{
+				Order:          snapshot,
+				PreviousStatus: order.Status,
+				Changes:        []string{websocket.ChangeStatus},
+				Actor:          actor,
+			})
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit status changes"})
+		return
+	}
+
+	if len(updated) > 0 {
+		// Jedna zbiorcza wiadomość WebSocket zamiast osobnej dla każdego zamówienia
+		h.hub.BroadcastBulkOrderUpdate(events)
+
+		// RabbitMQ - notification-service obsługuje powiadomienia per zamówienie
+		if h.publisher != nil {
+			for _, event := range events {
+				notification := publisher.OrderNotification{
+					Event:          models.EventOrderStatusChanged,
+					OrderID:        event.Order.ID,
+					CustomerName:   event.Order.CustomerName,
+					CustomerEmail:  event.Order.CustomerEmail,
+					CustomerLocale: event.Order.CustomerLocale,
+					Source:         string(event.Order.Source),
+					AssignedTo:     event.Order.AssignedTo,
+					Status:         string(req.Status),
+					PreviousStatus: string(event.PreviousStatus),
+					TotalAmount:    event.Order.TotalAmount,
+					Timestamp:      time.Now(),
+				}
+				if err := h.publisher.PublishOrderNotification(notification); err != nil {
+					log.Printf("Błąd publikacji powiadomienia dla zamówienia #%d: %v", event.Order.ID, err)
+				}
+			}
+		}
+
+		for _, order := range updated {
+			h.webhooks.DispatchOrderEvent(models.EventOrderStatusChanged, models.OrderEventData{
+				OrderID:        order.ID,
+				CustomerName:   order.CustomerName,
+				Source:         order.Source,
+				Status:         req.Status,
+				PreviousStatus: order.Status,
+				TotalAmount:    order.TotalAmount,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  req.Status,
+		"updated": len(updated),
+		"failed":  len(results) - len(updated),
+		"results": results,
+	})
+}
+
+// PUT /api/orders/:id/tags - Zastąpienie listy tagów zamówienia
+func (h *OrderHandler) UpdateOrderTags(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	tags := normalizeTags(req.Tags)
+	for _, tag := range tags {
+		if len(tag) > maxTagLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag too long"})
+			return
+		}
+	}
+	if !checkOrderLock(c, h.db, id) {
+		return
+	}
+
+	order, err := scanOrder(h.db.QueryRow(`
+		UPDATE orders SET tags = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+		RETURNING `+orderColumns, pq.Array(tags), id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order tags"})
+		return
+	}
+
+	h.hub.BroadcastOrderEvent(websocket.TypeOrderUpdated, websocket.OrderEventMessage{
+		Order:   order,
+		Changes: []string{websocket.ChangeTags},
+		Actor:   actorFromContext(c),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"order_id": id, "tags": tags})
+}
+
+// PUT /api/orders/:id/assignment - Przypisanie zamówienia do pracownika (user_id: null zdejmuje przypisanie)
+func (h *OrderHandler) AssignOrder(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID *int `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+
+	currentUser, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+		return
+	}
+	if !checkAssignee(c, h.users, req.UserID) {
+		return
+	}
+	if !checkOrderLock(c, h.db, id) {
+		return
+	}
+
+	order, err := scanOrder(h.db.QueryRow(`
+		UPDATE orders SET assigned_to = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+		RETURNING `+orderColumns, req.UserID, id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign order"})
+		return
+	}
+
+	h.hub.BroadcastOrderEvent(websocket.TypeOrderUpdated, websocket.OrderEventMessage{
+		Order:   order,
+		Changes: []string{websocket.ChangeAssignment},
+		Actor:   currentUser,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"order_id": id, "assigned_to": req.UserID})
+}
+
+// DELETE /api/orders/:id - Usunięcie zamówienia wraz z pozycjami, notatkami i załącznikami (admin)
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if !checkOrderLock(c, h.db, id) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Blokujemy zamówienie do końca transakcji - równoległy upload załącznika poczeka,
+	// a po usunięciu zamówienia jego zapis się nie powiedzie (upload sam usuwa wtedy plik)
+	var lockedID int
+	err = tx.QueryRow(`SELECT id FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	// Klucze plików pobieramy przed usunięciem - wiersze załączników znikną kaskadowo
+	rows, err := tx.Query(`SELECT storage_key FROM order_attachments WHERE order_id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	var storageKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+			return
+		}
+		storageKeys = append(storageKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	order, err := scanOrder(tx.QueryRow(`DELETE FROM orders WHERE id = $1 RETURNING `+orderColumns, id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit order deletion"})
+		return
+	}
+
+	// Pliki usuwamy dopiero po zatwierdzeniu - wycofane usunięcie nie gubi załączników
+	for _, key := range storageKeys {
+		if err := h.storage.Delete(key); err != nil && err != storage.ErrNotFound {
+			log.Printf("Błąd usuwania pliku załącznika %s: %v", key, err)
+		}
+	}
+
+	h.hub.BroadcastOrderEvent(websocket.TypeOrderDeleted, websocket.OrderEventMessage{
+		Order: order,
+		Actor: actorFromContext(c),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+const maxTagLength = 50
+
+// normalizeTags usuwa puste i zduplikowane tagi oraz sprowadza je do małych liter
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
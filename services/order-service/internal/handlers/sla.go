@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/iDos27/order-management/order-service/internal/sla"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SLAHandler struct {
+	monitor *sla.Monitor
+}
+
+func NewSLAHandler(monitor *sla.Monitor) *SLAHandler {
+	return &SLAHandler{monitor: monitor}
+}
+
+// GET /api/orders/sla - Zamówienia z przekroczonym lub zagrożonym SLA
+func (h *SLAHandler) GetAtRiskOrders(c *gin.Context) {
+	orders, err := h.monitor.AtRisk()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate SLA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
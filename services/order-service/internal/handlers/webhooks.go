@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type WebhookHandler struct {
+	db         *database.DB
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(db *database.DB, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{db: db, dispatcher: dispatcher}
+}
+
+var knownWebhookEvents = map[string]bool{
+	models.EventOrderCreated:       true,
+	models.EventOrderStatusChanged: true,
+	models.EventOrderSLABreached:   true,
+}
+
+// GET /api/webhooks - Lista subskrypcji
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT id, url, events, sources, active, created_at, updated_at
+		FROM webhook_subscriptions
+		ORDER BY id
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan webhook"})
+			return
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GET /api/webhooks/:id - Pojedyncza subskrypcja
+func (h *WebhookHandler) GetSubscription(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	sub, err := scanSubscription(h.db.QueryRow(`
+		SELECT id, url, events, sources, active, created_at, updated_at
+		FROM webhook_subscriptions WHERE id = $1
+	`, id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// POST /api/webhooks - Utworzenie subskrypcji
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+	if msg := validateSubscription(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Sekret generujemy, jeśli partner nie podał własnego
+	if req.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+		req.Secret = secret
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	sub := models.WebhookSubscription{
+		URL:     req.URL,
+		Secret:  req.Secret,
+		Events:  nonNilStrings(req.Events),
+		Sources: nonNilSources(req.Sources),
+		Active:  active,
+	}
+	err := h.db.QueryRow(`
+		INSERT INTO webhook_subscriptions (url, secret, events, sources, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, sub.URL, sub.Secret, pq.Array(sub.Events), pq.Array(sourceStrings(sub.Sources)), sub.Active).
+		Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	// Sekret zwracamy tylko raz - przy utworzeniu subskrypcji
+	c.JSON(http.StatusCreated, sub)
+}
+
+// PUT /api/webhooks/:id - Aktualizacja subskrypcji
+func (h *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON data"})
+		return
+	}
+	if msg := validateSubscription(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Pusty sekret lub brak pola active oznacza pozostawienie obecnej wartości
+	sub, err := scanSubscription(h.db.QueryRow(`
+		UPDATE webhook_subscriptions
+		SET url = $2, events = $3, sources = $4,
+		    secret = COALESCE(NULLIF($5, ''), secret),
+		    active = COALESCE($6, active),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, url, events, sources, active, created_at, updated_at
+	`, id, req.URL, pq.Array(nonNilStrings(req.Events)), pq.Array(sourceStrings(req.Sources)), req.Secret, req.Active))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// DELETE /api/webhooks/:id - Usunięcie subskrypcji wraz z logiem dostarczeń
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /api/webhooks/:id/ping - Testowe zdarzenie "ping" (np. do lokalnego stuba HTTP)
+func (h *WebhookHandler) PingSubscription(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	deliveryID, err := h.dispatcher.Ping(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"delivery_id": deliveryID})
+}
+
+// GET /api/webhooks/:id/deliveries - Log dostarczeń subskrypcji
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, subscription_id, event, payload, status, attempts, response_code,
+		       last_error, next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, id, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.Event, &payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan delivery"})
+			return
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// POST /api/webhooks/:id/deliveries/:deliveryId/redeliver - Ręczne ponowienie dostarczenia
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIDParam(c, "deliveryId")
+	if !ok {
+		return
+	}
+
+	newID, err := h.dispatcher.Redeliver(id, deliveryID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule redelivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"delivery_id": newID})
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row rowScanner) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var sources []string
+	err := row.Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), pq.Array(&sources),
+		&sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return sub, err
+	}
+	sub.Events = nonNilStrings(sub.Events)
+	sub.Sources = make([]models.OrderSource, 0, len(sources))
+	for _, s := range sources {
+		sub.Sources = append(sub.Sources, models.OrderSource(s))
+	}
+	return sub, nil
+}
+
+func validateSubscription(req models.WebhookSubscriptionRequest) string {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "Webhook URL must be an absolute http(s) URL"
+	}
+	for _, event := range req.Events {
+		if !knownWebhookEvents[event] {
+			return "Unknown webhook event: " + event
+		}
+	}
+	return ""
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nonNilSources(values []models.OrderSource) []models.OrderSource {
+	if values == nil {
+		return []models.OrderSource{}
+	}
+	return values
+}
+
+func sourceStrings(values []models.OrderSource) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, string(v))
+	}
+	return result
+}
+
+// parseIDParam odczytuje numeryczny parametr ścieżki, w razie błędu odpowiada 400
+func parseIDParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
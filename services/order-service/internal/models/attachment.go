@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Metadane załącznika zamówienia (zawartość w storage pod StorageKey)
+type OrderAttachment struct {
+	ID          int       `json:"id" db:"id"`
+	OrderID     int       `json:"order_id" db:"order_id"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	UploadedBy  int       `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Doradcza blokada edycji zamówienia - wygasa, jeśli nie zostanie odnowiona
+type OrderLock struct {
+	OrderID    int       `json:"order_id" db:"order_id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	UserEmail  string    `json:"user_email" db:"user_email"`
+	AcquiredAt time.Time `json:"acquired_at" db:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+}
@@ -14,6 +14,41 @@ const (
 	StatusCancelled OrderStatus = "cancelled"
 )
 
+// Dozwolone przejścia między statusami (zgodne z akcjami dostępnymi w panelu)
+var statusTransitions = map[OrderStatus][]OrderStatus{
+	StatusNew:       {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusShipped, StatusNew, StatusCancelled},
+	StatusShipped:   {StatusDelivered, StatusConfirmed, StatusCancelled},
+	StatusDelivered: {StatusShipped},
+	StatusCancelled: {StatusNew},
+}
+
+// IsValid sprawdza czy status jest jednym ze znanych statusów
+func (s OrderStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo sprawdza czy dozwolona jest zmiana statusu z s na target
+func (s OrderStatus) CanTransitionTo(target OrderStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Języki komunikacji z klientem (e-maile z notification-service)
+const DefaultLocale = "pl"
+
+var supportedLocales = map[string]bool{"pl": true, "en": true}
+
+// IsSupportedLocale sprawdza czy dla języka istnieją szablony powiadomień
+func IsSupportedLocale(locale string) bool {
+	return supportedLocales[locale]
+}
+
 type OrderSource string
 
 const (
@@ -24,15 +59,18 @@ const (
 )
 
 type Order struct {
-	ID            int         `json:"id" db:"id"`
-	CustomerName  string      `json:"customer_name" db:"customer_name"`
-	CustomerEmail string      `json:"customer_email" db:"customer_email"`
-	Source        OrderSource `json:"source" db:"source"`
-	Status        OrderStatus `json:"status" db:"status"`
-	TotalAmount   float64     `json:"total_amount" db:"total_amount"`
-	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
-	Items         []OrderItem `json:"items,omitempty" db:"-"`
+	ID             int         `json:"id" db:"id"`
+	CustomerName   string      `json:"customer_name" db:"customer_name"`
+	CustomerEmail  string      `json:"customer_email" db:"customer_email"`
+	CustomerLocale string      `json:"customer_locale" db:"customer_locale"` // język e-maili do klienta (pl / en)
+	Source         OrderSource `json:"source" db:"source"`
+	Status         OrderStatus `json:"status" db:"status"`
+	TotalAmount    float64     `json:"total_amount" db:"total_amount"`
+	Tags           []string    `json:"tags" db:"tags"`
+	AssignedTo     *int        `json:"assigned_to" db:"assigned_to"` // ID pracownika z auth-service
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+	Items          []OrderItem `json:"items,omitempty" db:"-"`
 }
 
 type OrderItem struct {
@@ -42,3 +80,27 @@ type OrderItem struct {
 	Quantity    int     `json:"quantity" db:"quantity"`
 	Price       float64 `json:"price" db:"price"`
 }
+
+// Wewnętrzna notatka pracownika do zamówienia
+type OrderNote struct {
+	ID          int       `json:"id" db:"id"`
+	OrderID     int       `json:"order_id" db:"order_id"`
+	AuthorID    int       `json:"author_id" db:"author_id"`
+	AuthorEmail string    `json:"author_email" db:"author_email"`
+	Body        string    `json:"body" db:"body"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Zbiorcza zmiana statusu wielu zamówień
+type BulkStatusUpdateRequest struct {
+	OrderIDs []int       `json:"order_ids" binding:"required,min=1,max=500"`
+	Status   OrderStatus `json:"status" binding:"required"`
+}
+
+// Wynik zmiany statusu pojedynczego zamówienia w operacji zbiorczej
+type BulkStatusResult struct {
+	OrderID        int         `json:"order_id"`
+	Success        bool        `json:"success"`
+	PreviousStatus OrderStatus `json:"previous_status,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
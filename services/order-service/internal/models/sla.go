@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Stan SLA zamówienia czekającego w monitorowanym statusie
+type OrderSLA struct {
+	OrderID          int         `json:"order_id"`
+	CustomerName     string      `json:"customer_name"`
+	Source           OrderSource `json:"source"`
+	Status           OrderStatus `json:"status"`
+	TotalAmount      float64     `json:"total_amount"`
+	AssignedTo       *int        `json:"assigned_to,omitempty"`
+	StatusChangedAt  time.Time   `json:"status_changed_at"`
+	ThresholdSeconds int64       `json:"threshold_seconds"`
+	AgeSeconds       int64       `json:"age_seconds"`
+	Deadline         time.Time   `json:"deadline"`
+	Breached         bool        `json:"breached"`
+	BreachedAt       *time.Time  `json:"breached_at,omitempty"`
+}
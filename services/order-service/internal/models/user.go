@@ -0,0 +1,14 @@
+package models
+
+// Użytkownik z tokenu JWT wystawionego przez auth-service
+type CurrentUser struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+const (
+	RoleAdmin    = "admin"    // Admin
+	RoleEmployee = "employee" // Pracownik
+	RoleCustomer = "customer" // Klient
+)
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Typy zdarzeń zamówień (webhooki, RabbitMQ)
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status_changed"
+	EventOrderSLABreached   = "order.sla_breached"
+	EventPing               = "ping"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending WebhookDeliveryStatus = "pending"
+	DeliverySuccess WebhookDeliveryStatus = "success"
+	DeliveryFailed  WebhookDeliveryStatus = "failed"
+)
+
+// Subskrypcja webhooka partnera
+type WebhookSubscription struct {
+	ID        int           `json:"id" db:"id"`
+	URL       string        `json:"url" db:"url"`
+	Secret    string        `json:"secret,omitempty" db:"secret"` // zwracany tylko przy tworzeniu
+	Events    []string      `json:"events" db:"events"`           // puste = wszystkie zdarzenia
+	Sources   []OrderSource `json:"sources" db:"sources"`         // puste = wszystkie źródła
+	Active    bool          `json:"active" db:"active"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+type WebhookSubscriptionRequest struct {
+	URL     string        `json:"url" binding:"required,url"`
+	Secret  string        `json:"secret"`
+	Events  []string      `json:"events"`
+	Sources []OrderSource `json:"sources"`
+	Active  *bool         `json:"active"`
+}
+
+// Wpis w logu dostarczeń webhooka
+type WebhookDelivery struct {
+	ID             int                   `json:"id" db:"id"`
+	SubscriptionID int                   `json:"subscription_id" db:"subscription_id"`
+	Event          string                `json:"event" db:"event"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	ResponseCode   *int                  `json:"response_code,omitempty" db:"response_code"`
+	LastError      *string               `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+}
+
+// Treść wysyłana do partnera
+type WebhookEvent struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dane zamówienia w zdarzeniu webhooka
+type OrderEventData struct {
+	OrderID        int         `json:"order_id"`
+	CustomerName   string      `json:"customer_name"`
+	Source         OrderSource `json:"source"`
+	Status         OrderStatus `json:"status"`
+	PreviousStatus OrderStatus `json:"previous_status,omitempty"`
+	TotalAmount    float64     `json:"total_amount"`
+}
@@ -2,6 +2,8 @@ package publisher
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"log"
 	"time"
@@ -16,11 +18,18 @@ type Publisher struct {
 }
 
 type OrderNotification struct {
-	OrderID      int       `json:"order_id"`
-	CustomerName string    `json:"customer_name"`
-	Status       string    `json:"status"`
-	TotalAmount  float64   `json:"total_amount"`
-	Timestamp    time.Time `json:"timestamp"`
+	EventID        string    `json:"event_id"`        // unikalny identyfikator zdarzenia (nadawany przy publikacji)
+	Event          string    `json:"event,omitempty"` // np. order.created, order.status_changed, order.sla_breached
+	OrderID        int       `json:"order_id"`
+	CustomerName   string    `json:"customer_name"`
+	CustomerEmail  string    `json:"customer_email,omitempty"`  // adresat e-maili o zmianach statusu
+	CustomerLocale string    `json:"customer_locale,omitempty"` // język e-maili (pl / en)
+	Source         string    `json:"source,omitempty"`
+	AssignedTo     *int      `json:"assigned_to,omitempty"` // ID przypisanego pracownika
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"`
+	TotalAmount    float64   `json:"total_amount"`
+	Timestamp      time.Time `json:"timestamp"`
 }
 
 func NewPublisher(rabbitMQURL, queueName string) (*Publisher, error) {
@@ -60,6 +69,9 @@ func NewPublisher(rabbitMQURL, queueName string) (*Publisher, error) {
 }
 
 func (p *Publisher) PublishOrderNotification(notification OrderNotification) error {
+	if notification.EventID == "" {
+		notification.EventID = newEventID()
+	}
 	body, err := json.Marshal(notification)
 	if err != nil {
 		return err
@@ -76,6 +88,7 @@ func (p *Publisher) PublishOrderNotification(notification OrderNotification) err
 		false,   // immediate
 		amqp.Publishing{
 			ContentType: "application/json",
+			MessageId:   notification.EventID,
 			Body:        body,
 			Timestamp:   time.Now(),
 		},
@@ -90,6 +103,13 @@ func (p *Publisher) PublishOrderNotification(notification OrderNotification) err
 	return nil
 }
 
+// newEventID zwraca losowy identyfikator zdarzenia (32 znaki hex)
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func (p *Publisher) Close() {
 	if p.channel != nil {
 		p.channel.Close()
@@ -0,0 +1,242 @@
+package sla
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+	"github.com/iDos27/order-management/order-service/internal/publisher"
+	"github.com/iDos27/order-management/order-service/internal/webhooks"
+	"github.com/iDos27/order-management/order-service/internal/websocket"
+
+	"github.com/lib/pq"
+)
+
+// Thresholds - maksymalny czas w danym statusie; klucz "status" lub "status/źródło"
+type Thresholds map[string]time.Duration
+
+// ParseThresholds parsuje konfigurację w formacie "new=4h,confirmed=24h,new/źródło_dwa=1h"
+func ParseThresholds(spec string) (Thresholds, error) {
+	thresholds := make(Thresholds)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("nieprawidłowy próg SLA %q (oczekiwano status[/źródło]=czas)", part)
+		}
+		status, _, _ := strings.Cut(key, "/")
+		if !models.OrderStatus(status).IsValid() {
+			return nil, fmt.Errorf("nieznany status w progu SLA: %q", status)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("nieprawidłowy czas w progu SLA %q", part)
+		}
+		thresholds[key] = d
+	}
+	return thresholds, nil
+}
+
+// For zwraca próg dla statusu i źródła (próg dla pary ma pierwszeństwo przed progiem statusu)
+func (t Thresholds) For(status models.OrderStatus, source models.OrderSource) (time.Duration, bool) {
+	if d, ok := t[string(status)+"/"+string(source)]; ok {
+		return d, true
+	}
+	d, ok := t[string(status)]
+	return d, ok
+}
+
+func (t Thresholds) statuses() []string {
+	seen := make(map[string]bool)
+	var result []string
+	for key := range t {
+		status, _, _ := strings.Cut(key, "/")
+		if !seen[status] {
+			seen[status] = true
+			result = append(result, status)
+		}
+	}
+	return result
+}
+
+type Config struct {
+	Thresholds Thresholds
+	Interval   time.Duration
+	// Ułamek progu, od którego zamówienie uznajemy za zagrożone (np. 0.8)
+	WarningRatio float64
+}
+
+// Monitor okresowo sprawdza zamówienia w monitorowanych statusach i oznacza przekroczenia SLA
+type Monitor struct {
+	db        *database.DB
+	hub       *websocket.Hub
+	publisher *publisher.Publisher
+	webhooks  *webhooks.Dispatcher
+	config    Config
+}
+
+func NewMonitor(db *database.DB, hub *websocket.Hub, pub *publisher.Publisher, wh *webhooks.Dispatcher, config Config) *Monitor {
+	return &Monitor{db: db, hub: hub, publisher: pub, webhooks: wh, config: config}
+}
+
+// Run sprawdza SLA co config.Interval do zamknięcia kanału stop
+func (m *Monitor) Run(stop <-chan struct{}) {
+	if len(m.config.Thresholds) == 0 {
+		log.Println("Monitor SLA wyłączony - brak skonfigurowanych progów")
+		return
+	}
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(); err != nil {
+			log.Printf("Błąd sprawdzania SLA: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check oznacza zamówienia przekraczające SLA i publikuje zdarzenie order.sla_breached
+// (każde przekroczenie raz - do następnej zmiany statusu, która zeruje sla_breached_at)
+func (m *Monitor) Check() error {
+	orders, err := m.load(false)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, o := range orders {
+		if now.Before(o.Deadline) {
+			continue
+		}
+
+		// Warunek na status chroni przed oznaczeniem zamówienia, którego status właśnie się zmienił
+		result, err := m.db.Exec(`
+			UPDATE orders SET sla_breached_at = NOW()
+			WHERE id = $1 AND sla_breached_at IS NULL AND status = $2
+		`, o.OrderID, o.Status)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		o.Breached = true
+		o.BreachedAt = &now
+		o.AgeSeconds = int64(now.Sub(o.StatusChangedAt).Seconds())
+		m.publish(o)
+	}
+	return nil
+}
+
+// AtRisk zwraca zamówienia z przekroczonym SLA lub zbliżające się do progu, najpilniejsze pierwsze
+func (m *Monitor) AtRisk() ([]models.OrderSLA, error) {
+	orders, err := m.load(true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := []models.OrderSLA{}
+	for _, o := range orders {
+		threshold := time.Duration(o.ThresholdSeconds) * time.Second
+		age := now.Sub(o.StatusChangedAt)
+		if o.Breached || float64(age) >= float64(threshold)*m.config.WarningRatio {
+			result = append(result, o)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Deadline.Before(result[j].Deadline)
+	})
+	return result, nil
+}
+
+// load pobiera zamówienia w monitorowanych statusach i wylicza dla nich próg i termin
+func (m *Monitor) load(includeBreached bool) ([]models.OrderSLA, error) {
+	statuses := m.config.Thresholds.statuses()
+	if len(statuses) == 0 {
+		return []models.OrderSLA{}, nil
+	}
+
+	rows, err := m.db.Query(`
+		SELECT id, customer_name, source, status, total_amount, assigned_to,
+		       COALESCE(status_changed_at, updated_at), sla_breached_at
+		FROM orders
+		WHERE status = ANY($1) AND ($2 OR sla_breached_at IS NULL)
+	`, pq.Array(statuses), includeBreached)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var orders []models.OrderSLA
+	for rows.Next() {
+		var o models.OrderSLA
+		if err := rows.Scan(&o.OrderID, &o.CustomerName, &o.Source, &o.Status, &o.TotalAmount, &o.AssignedTo,
+			&o.StatusChangedAt, &o.BreachedAt); err != nil {
+			return nil, err
+		}
+		threshold, ok := m.config.Thresholds.For(o.Status, o.Source)
+		if !ok {
+			continue
+		}
+		o.ThresholdSeconds = int64(threshold.Seconds())
+		o.Deadline = o.StatusChangedAt.Add(threshold)
+		o.AgeSeconds = int64(now.Sub(o.StatusChangedAt).Seconds())
+		o.Breached = o.BreachedAt != nil
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (m *Monitor) publish(o models.OrderSLA) {
+	log.Printf("Przekroczone SLA zamówienia #%d (status: %s, źródło: %s, od %s)",
+		o.OrderID, o.Status, o.Source, o.StatusChangedAt.Format(time.RFC3339))
+
+	m.hub.BroadcastEvent(websocket.TypeSLABreached, o, websocket.OrderRef{
+		ID:         o.OrderID,
+		Status:     o.Status,
+		Source:     o.Source,
+		AssignedTo: o.AssignedTo,
+	})
+
+	if m.publisher != nil {
+		notification := publisher.OrderNotification{
+			Event:        models.EventOrderSLABreached,
+			OrderID:      o.OrderID,
+			CustomerName: o.CustomerName,
+			Source:       string(o.Source),
+			AssignedTo:   o.AssignedTo,
+			Status:       string(o.Status),
+			TotalAmount:  o.TotalAmount,
+			Timestamp:    time.Now(),
+		}
+		if err := m.publisher.PublishOrderNotification(notification); err != nil {
+			log.Printf("Błąd publikacji zdarzenia SLA dla zamówienia #%d: %v", o.OrderID, err)
+		}
+	}
+
+	m.webhooks.DispatchOrderEvent(models.EventOrderSLABreached, models.OrderEventData{
+		OrderID:      o.OrderID,
+		CustomerName: o.CustomerName,
+		Source:       o.Source,
+		Status:       o.Status,
+		TotalAmount:  o.TotalAmount,
+	})
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("obiekt nie istnieje")
+
+// Storage przechowuje zawartość plików pod kluczami generowanymi przez serwis
+type Storage interface {
+	// Save zapisuje zawartość i zwraca liczbę zapisanych bajtów
+	Save(key string, r io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,62 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/models"
+)
+
+// Directory sprawdza użytkowników w auth-service (order-service nie ma własnej tabeli
+// użytkowników). Zapytanie wykonywane jest tokenem zalogowanego pracownika.
+type Directory struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewDirectory(baseURL string, timeout time.Duration) *Directory {
+	return &Directory{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// IsStaff sprawdza, czy użytkownik istnieje i ma rolę admin lub employee.
+// authorization - nagłówek Authorization bieżącego żądania.
+func (d *Directory) IsStaff(userID int, authorization string) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, d.baseURL+"/api/v1/users/"+strconv.Itoa(userID), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return false, fmt.Errorf("odpowiedź auth-service HTTP %d", resp.StatusCode)
+	}
+
+	var body struct {
+		User struct {
+			Role string `json:"role"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body); err != nil {
+		return false, fmt.Errorf("błędna odpowiedź auth-service: %w", err)
+	}
+	return body.User.Role == models.RoleAdmin || body.User.Role == models.RoleEmployee, nil
+}
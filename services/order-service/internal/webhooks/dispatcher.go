@@ -0,0 +1,298 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+	"github.com/iDos27/order-management/order-service/internal/models"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	// Czas "dzierżawy" dostarczenia - po tym czasie niedokończone dostarczenie zostanie ponowione
+	claimLease = 5 * time.Minute
+	batchSize  = 20
+)
+
+type Config struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Dispatcher zapisuje zdarzenia do logu dostarczeń i wysyła je do subskrybentów
+type Dispatcher struct {
+	db     *database.DB
+	client *http.Client
+	config Config
+	wake   chan struct{}
+}
+
+func NewDispatcher(db *database.DB, config Config) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Sign zwraca podpis HMAC-SHA256 treści w formacie "sha256=<hex>"
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DispatchOrderEvent tworzy dostarczenia dla wszystkich pasujących subskrypcji
+func (d *Dispatcher) DispatchOrderEvent(event string, data models.OrderEventData) {
+	payload, err := json.Marshal(models.WebhookEvent{
+		Event:     event,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Błąd serializacji zdarzenia webhooka: %v", err)
+		return
+	}
+
+	// Puste tablice events/sources oznaczają subskrypcję wszystkich zdarzeń/źródeł
+	result, err := d.db.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event, payload, status, next_attempt_at)
+		SELECT id, $1, $2, 'pending', NOW()
+		FROM webhook_subscriptions
+		WHERE active
+		  AND (cardinality(events) = 0 OR $1 = ANY(events))
+		  AND (cardinality(sources) = 0 OR $3 = ANY(sources))
+	`, event, string(payload), string(data.Source))
+	if err != nil {
+		log.Printf("Błąd zapisu dostarczeń webhooka (%s): %v", event, err)
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		d.notify()
+	}
+}
+
+// Ping tworzy dostarczenie testowe dla jednej subskrypcji
+func (d *Dispatcher) Ping(subscriptionID int) (int, error) {
+	payload, err := json.Marshal(models.WebhookEvent{
+		Event:     models.EventPing,
+		Timestamp: time.Now(),
+		Data:      map[string]int{"subscription_id": subscriptionID},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var deliveryID int
+	err = d.db.QueryRow(`
+		INSERT INTO webhook_deliveries (subscription_id, event, payload, status, next_attempt_at)
+		VALUES ($1, $2, $3, 'pending', NOW())
+		RETURNING id
+	`, subscriptionID, models.EventPing, string(payload)).Scan(&deliveryID)
+	if err != nil {
+		return 0, err
+	}
+
+	d.notify()
+	return deliveryID, nil
+}
+
+// Redeliver tworzy nowe dostarczenie z treścią istniejącego wpisu w logu
+func (d *Dispatcher) Redeliver(subscriptionID, deliveryID int) (int, error) {
+	var newID int
+	err := d.db.QueryRow(`
+		INSERT INTO webhook_deliveries (subscription_id, event, payload, status, next_attempt_at)
+		SELECT subscription_id, event, payload, 'pending', NOW()
+		FROM webhook_deliveries
+		WHERE id = $1 AND subscription_id = $2
+		RETURNING id
+	`, deliveryID, subscriptionID).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	d.notify()
+	return newID, nil
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run przetwarza oczekujące dostarczenia do zamknięcia kanału stop
+func (d *Dispatcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.processDue()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+type pendingDelivery struct {
+	id       int
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+func (d *Dispatcher) processDue() {
+	for {
+		deliveries, err := d.claimDue()
+		if err != nil {
+			log.Printf("Błąd pobierania dostarczeń webhooków: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.deliver(delivery)
+		}
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// claimDue rezerwuje dostarczenia gotowe do wysłania, przesuwając next_attempt_at o czas dzierżawy,
+// dzięki czemu kilka replik nie wyśle tego samego dostarczenia jednocześnie
+func (d *Dispatcher) claimDue() ([]pendingDelivery, error) {
+	rows, err := d.db.Query(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $1)
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+		  AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		  )
+		RETURNING d.id, d.event, d.payload, d.attempts, s.url, s.secret
+	`, claimLease.Seconds(), batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []pendingDelivery
+	for rows.Next() {
+		var p pendingDelivery
+		if err := rows.Scan(&p.id, &p.event, &p.payload, &p.attempts, &p.url, &p.secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, p)
+	}
+	return deliveries, rows.Err()
+}
+
+func (d *Dispatcher) deliver(p pendingDelivery) {
+	attempt := p.attempts + 1
+	code, err := d.send(p)
+
+	var responseCode interface{}
+	if code != 0 {
+		responseCode = code
+	}
+
+	if err == nil {
+		_, dbErr := d.db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'success', attempts = $2, response_code = $3, last_error = NULL,
+			    next_attempt_at = NULL, delivered_at = NOW()
+			WHERE id = $1
+		`, p.id, attempt, responseCode)
+		if dbErr != nil {
+			log.Printf("Błąd zapisu wyniku dostarczenia #%d: %v", p.id, dbErr)
+		}
+		return
+	}
+
+	log.Printf("Nieudane dostarczenie webhooka #%d do %s (próba %d/%d): %v",
+		p.id, p.url, attempt, d.config.MaxAttempts, err)
+
+	if attempt >= d.config.MaxAttempts {
+		_, dbErr := d.db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'failed', attempts = $2, response_code = $3, last_error = $4, next_attempt_at = NULL
+			WHERE id = $1
+		`, p.id, attempt, responseCode, err.Error())
+		if dbErr != nil {
+			log.Printf("Błąd zapisu wyniku dostarczenia #%d: %v", p.id, dbErr)
+		}
+		return
+	}
+
+	_, dbErr := d.db.Exec(`
+		UPDATE webhook_deliveries
+		SET attempts = $2, response_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $1
+	`, p.id, attempt, responseCode, err.Error(), time.Now().Add(d.backoff(attempt)))
+	if dbErr != nil {
+		log.Printf("Błąd zapisu wyniku dostarczenia #%d: %v", p.id, dbErr)
+	}
+}
+
+// backoff - wykładnicze opóźnienie kolejnej próby: base * 2^(attempt-1), ograniczone przez MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.config.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) send(p pendingDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(p.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks/1.0")
+	req.Header.Set(EventHeader, p.event)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(p.id))
+	req.Header.Set(SignatureHeader, Sign(p.secret, p.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("odpowiedź HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+)
+
+// Co ile usuwane są zdarzenia starsze niż okres retencji
+const pruneInterval = 10 * time.Minute
+
+type EventLogConfig struct {
+	// Jak długo zdarzenia są dostępne do odtworzenia
+	Retention time.Duration
+	// Maksymalna liczba zdarzeń odtwarzanych po ponownym połączeniu -
+	// klient bardziej zapóźniony dostaje "resync_required"
+	ReplayLimit int
+}
+
+// EventLog - trwały log zdarzeń rozsyłanych przez hub; numer sekwencyjny (seq) zdarzenia
+// to klucz tabeli ws_events, dzięki czemu jest rosnący również po restarcie serwisu
+type EventLog struct {
+	db     *database.DB
+	config EventLogConfig
+}
+
+func NewEventLog(db *database.DB, config EventLogConfig) *EventLog {
+	return &EventLog{db: db, config: config}
+}
+
+// Append zapisuje zdarzenie i zwraca nadany mu numer sekwencyjny
+func (l *EventLog) Append(message Message) (int64, error) {
+	payload, err := json.Marshal(message.Payload)
+	if err != nil {
+		return 0, err
+	}
+	orders, err := json.Marshal(nonNilRefs(message.orders))
+	if err != nil {
+		return 0, err
+	}
+
+	var seq int64
+	err = l.db.QueryRow(`
+		INSERT INTO ws_events (type, payload, staff_only, orders)
+		VALUES ($1, $2, $3, $4)
+		RETURNING seq
+	`, message.Type, string(payload), message.staffOnly, string(orders)).Scan(&seq)
+	return seq, err
+}
+
+// Latest zwraca numer ostatniego zapisanego zdarzenia (0 - log pusty)
+func (l *EventLog) Latest() (int64, error) {
+	var seq int64
+	err := l.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM ws_events`).Scan(&seq)
+	return seq, err
+}
+
+// Since zwraca zdarzenia o numerach z przedziału (after, upTo]. ok == false oznacza, że
+// zdarzeń nie da się odtworzyć (usunięte przez retencję, za dużo zdarzeń lub nieznany numer)
+func (l *EventLog) Since(after, upTo int64) (messages []Message, ok bool, err error) {
+	if after > upTo || upTo-after > int64(l.config.ReplayLimit) {
+		return nil, false, nil
+	}
+	if after == upTo {
+		return nil, true, nil
+	}
+
+	// Pierwsze brakujące zdarzenie musi nadal być w logu
+	var oldest int64
+	if err := l.db.QueryRow(`SELECT COALESCE(MIN(seq), 0) FROM ws_events`).Scan(&oldest); err != nil {
+		return nil, false, err
+	}
+	if oldest == 0 || oldest > after+1 {
+		return nil, false, nil
+	}
+
+	messages, err = l.between(after, upTo)
+	if err != nil {
+		return nil, false, err
+	}
+	return messages, true, nil
+}
+
+// between zwraca zapisane zdarzenia o numerach z przedziału (after, upTo] bez sprawdzania,
+// czy przedział jest kompletny
+func (l *EventLog) between(after, upTo int64) ([]Message, error) {
+	rows, err := l.db.Query(`
+		SELECT seq, type, payload, staff_only, orders
+		FROM ws_events
+		WHERE seq > $1 AND seq <= $2
+		ORDER BY seq
+	`, after, upTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		m, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// Get odczytuje pojedyncze zdarzenie (np. ogłoszone przez inną replikę)
+func (l *EventLog) Get(seq int64) (Message, error) {
+	return scanEvent(l.db.QueryRow(`
+		SELECT seq, type, payload, staff_only, orders FROM ws_events WHERE seq = $1
+	`, seq))
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row rowScanner) (Message, error) {
+	var m Message
+	var payload, orders []byte
+	if err := row.Scan(&m.Seq, &m.Type, &payload, &m.staffOnly, &orders); err != nil {
+		return m, err
+	}
+	m.Payload = json.RawMessage(payload)
+	err := json.Unmarshal(orders, &m.orders)
+	return m, err
+}
+
+// Run usuwa stare zdarzenia do zamknięcia kanału stop
+func (l *EventLog) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		l.prune()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *EventLog) prune() {
+	result, err := l.db.Exec(`
+		DELETE FROM ws_events WHERE created_at < NOW() - make_interval(secs => $1)
+	`, l.config.Retention.Seconds())
+	if err != nil {
+		log.Printf("Błąd czyszczenia logu zdarzeń WebSocket: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Usunięto %d starych zdarzeń WebSocket", n)
+	}
+}
+
+func nonNilRefs(refs []OrderRef) []OrderRef {
+	if refs == nil {
+		return []OrderRef{}
+	}
+	return refs
+}
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Fanout rozsyła zdarzenia huba między replikami order-service. Każda replika publikuje
+// własne zdarzenia i rozgłasza lokalnie zdarzenia otrzymane od pozostałych replik.
+type Fanout interface {
+	// Publish przekazuje zdarzenie (z nadanym seq) pozostałym replikom
+	Publish(message Message) error
+	// Run dostarcza zdarzenia innych replik do deliver do zamknięcia kanału stop
+	Run(stop <-chan struct{}, deliver func(Message))
+	Close()
+}
+
+// NewOrigin generuje losowy identyfikator repliki - pozwala pominąć własne zdarzenia
+// wracające przez backend fan-out
+func NewOrigin() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// remoteEvent - zdarzenie przesyłane między replikami (Message bez pól prywatnych)
+type remoteEvent struct {
+	Origin    string          `json:"origin"`
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	StaffOnly bool            `json:"staff_only,omitempty"`
+	Orders    []OrderRef      `json:"orders,omitempty"`
+}
+
+func encodeRemote(origin string, message Message) ([]byte, error) {
+	payload, err := json.Marshal(message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(remoteEvent{
+		Origin:    origin,
+		Seq:       message.Seq,
+		Type:      message.Type,
+		Payload:   payload,
+		StaffOnly: message.staffOnly,
+		Orders:    message.orders,
+	})
+}
+
+func (e remoteEvent) message() Message {
+	return Message{
+		Type:      e.Type,
+		Seq:       e.Seq,
+		Payload:   e.Payload,
+		staffOnly: e.StaffOnly,
+		orders:    e.Orders,
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Kanał NOTIFY, na którym repliki ogłaszają nowe zdarzenia
+const notifyChannel = "ws_events"
+
+// PostgresFanout - fan-out przez LISTEN/NOTIFY. Treść NOTIFY jest ograniczona do 8000 bajtów,
+// dlatego przesyłany jest tylko numer zdarzenia, a pozostałe repliki odczytują je z logu ws_events.
+type PostgresFanout struct {
+	db       *database.DB
+	events   *EventLog
+	listener *pq.Listener
+	origin   string
+}
+
+func NewPostgresFanout(db *database.DB, events *EventLog, connString, origin string) (*PostgresFanout, error) {
+	if events == nil {
+		return nil, fmt.Errorf("fan-out przez PostgreSQL wymaga logu zdarzeń")
+	}
+
+	listener := pq.NewListener(connString, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Listener PostgreSQL (fan-out WebSocket): %v", err)
+		}
+	})
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	log.Printf("Fan-out WebSocket przez PostgreSQL LISTEN/NOTIFY (kanał %s)", notifyChannel)
+	return &PostgresFanout{db: db, events: events, listener: listener, origin: origin}, nil
+}
+
+func (f *PostgresFanout) Publish(message Message) error {
+	if message.Seq == 0 {
+		return fmt.Errorf("zdarzenie %s nie zostało zapisane w logu", message.Type)
+	}
+	body, err := json.Marshal(remoteEvent{Origin: f.origin, Seq: message.Seq})
+	if err != nil {
+		return err
+	}
+	_, err = f.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(body))
+	return err
+}
+
+func (f *PostgresFanout) Run(stop <-chan struct{}, deliver func(Message)) {
+	for {
+		select {
+		case <-stop:
+			return
+		case n := <-f.listener.Notify:
+			// nil - połączenie zostało odtworzone; zdarzenia z przerwy klienci odzyskają przez last_seq
+			if n == nil {
+				log.Println("Listener PostgreSQL połączony ponownie")
+				continue
+			}
+			var ev remoteEvent
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				log.Printf("Nieprawidłowe powiadomienie fan-out: %v", err)
+				continue
+			}
+			if ev.Origin == f.origin {
+				continue
+			}
+			message, err := f.events.Get(ev.Seq)
+			if err != nil {
+				log.Printf("Błąd odczytu zdarzenia #%d z logu: %v", ev.Seq, err)
+				continue
+			}
+			deliver(message)
+		case <-time.After(90 * time.Second):
+			// Sprawdzenie, czy połączenie nasłuchujące nadal działa
+			go f.listener.Ping()
+		}
+	}
+}
+
+func (f *PostgresFanout) Close() {
+	f.listener.Close()
+}
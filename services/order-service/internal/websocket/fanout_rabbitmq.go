@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Odstęp między próbami ponownego połączenia (jak w listenerze PostgreSQL)
+const (
+	fanoutReconnectMinDelay = time.Second
+	fanoutReconnectMaxDelay = time.Minute
+)
+
+// RabbitMQFanout - fan-out przez exchange typu fanout; każda replika ma własną
+// tymczasową kolejkę (exclusive, auto-delete) związaną z exchange. Po zerwaniu
+// połączenia Run łączy się ponownie i deklaruje kolejkę od nowa.
+type RabbitMQFanout struct {
+	url      string
+	exchange string
+	origin   string
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   string
+}
+
+func NewRabbitMQFanout(rabbitMQURL, exchange, origin string) (*RabbitMQFanout, error) {
+	f := &RabbitMQFanout{url: rabbitMQURL, exchange: exchange, origin: origin}
+	if err := f.connect(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// connect otwiera połączenie, deklaruje exchange oraz kolejkę repliki i zastępuje
+// poprzednie (zerwane) połączenie
+func (f *RabbitMQFanout) connect() error {
+	conn, err := amqp.Dial(f.url)
+	if err != nil {
+		return err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	err = channel.ExchangeDeclare(
+		f.exchange,
+		amqp.ExchangeFanout,
+		true,  // durable
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Kolejka repliki - usuwana razem z połączeniem
+	queue, err := channel.QueueDeclare(
+		"",    // nazwa nadana przez serwer
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := channel.QueueBind(queue.Name, "", f.exchange, false, nil); err != nil {
+		conn.Close()
+		return err
+	}
+
+	f.mu.Lock()
+	if f.conn != nil {
+		f.conn.Close()
+	}
+	f.conn, f.channel, f.queue = conn, channel, queue.Name
+	f.mu.Unlock()
+
+	log.Printf("Fan-out WebSocket przez RabbitMQ (exchange %s, kolejka %s)", f.exchange, queue.Name)
+	return nil
+}
+
+func (f *RabbitMQFanout) Publish(message Message) error {
+	body, err := encodeRemote(f.origin, message)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	channel := f.channel
+	f.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return channel.PublishWithContext(
+		ctx,
+		f.exchange, // exchange
+		"",         // routing key (ignorowany przez fanout)
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+			Timestamp:   time.Now(),
+		},
+	)
+}
+
+// Run odbiera zdarzenia i nadzoruje połączenie: po jego zerwaniu łączy się ponownie
+// z rosnącym odstępem. Zdarzeń z przerwy nie trzeba odbierać - hub odczytuje luki
+// w numeracji z logu, a klienci odzyskują resztę przez last_seq.
+func (f *RabbitMQFanout) Run(stop <-chan struct{}, deliver func(Message)) {
+	for {
+		err := f.consume(stop, deliver)
+		if err == nil {
+			return
+		}
+		log.Printf("Przerwano odbiór fan-out z RabbitMQ: %v", err)
+
+		delay := fanoutReconnectMinDelay
+		for {
+			log.Printf("Ponowne łączenie fan-out z RabbitMQ za %v...", delay)
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, fanoutReconnectMaxDelay)
+
+			if err := f.connect(); err != nil {
+				log.Printf("Nieudane ponowne połączenie fan-out: %v", err)
+				continue
+			}
+			break
+		}
+	}
+}
+
+// consume przekazuje zdarzenia do deliver do zamknięcia kanału stop (zwraca nil)
+// albo zamknięcia kanału RabbitMQ (zwraca powód)
+func (f *RabbitMQFanout) consume(stop <-chan struct{}, deliver func(Message)) error {
+	f.mu.Lock()
+	channel, queue := f.channel, f.queue
+	f.mu.Unlock()
+
+	closed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	deliveries, err := channel.Consume(
+		queue,
+		"",    // consumer
+		true,  // auto-ack - zdarzenia utracone przy awarii klienci odzyskają przez last_seq
+		true,  // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		return fmt.Errorf("błąd subskrypcji kolejki fan-out: %w", err)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				select {
+				case reason, ok := <-closed:
+					if ok && reason != nil {
+						return fmt.Errorf("zamknięto kanał RabbitMQ: %w", reason)
+					}
+				default:
+				}
+				return fmt.Errorf("zamknięto kanał RabbitMQ")
+			}
+			var ev remoteEvent
+			if err := json.Unmarshal(d.Body, &ev); err != nil {
+				log.Printf("Nieprawidłowe zdarzenie fan-out: %v", err)
+				continue
+			}
+			if ev.Origin == f.origin {
+				continue
+			}
+			deliver(ev.message())
+		}
+	}
+}
+
+func (f *RabbitMQFanout) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.channel != nil {
+		f.channel.Close()
+	}
+	if f.conn != nil {
+		f.conn.Close()
+	}
+}
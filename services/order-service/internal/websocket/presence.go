@@ -0,0 +1,178 @@
+package websocket
+
+import "sort"
+
+// Maksymalna liczba zamówień oglądanych jednocześnie przez jedno połączenie
+const maxViewedOrders = 50
+
+// Pracownik połączony z hubem wraz z zamówieniami, które ma otwarte
+type PresenceUser struct {
+	UserID  int    `json:"user_id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Viewing []int  `json:"viewing"`
+}
+
+// Wiadomość "presence": zmiana (joined / left / updated) lub stan początkowy (snapshot)
+// wraz z pełną listą obecnych pracowników
+type PresenceMessage struct {
+	Event string         `json:"event"`
+	User  *PresenceUser  `json:"user,omitempty"`
+	Users []PresenceUser `json:"users"`
+}
+
+const (
+	PresenceJoined   = "joined"
+	PresenceLeft     = "left"
+	PresenceUpdated  = "updated"
+	PresenceSnapshot = "snapshot"
+)
+
+type presenceEntry struct {
+	user        PresenceUser
+	connections int
+}
+
+// Zmiana oglądanego zamówienia zgłoszona przez klienta (view_order / leave_order)
+type viewChange struct {
+	client  *Client
+	orderID int
+	viewing bool
+}
+
+// join zlicza połączenie pracownika; pierwsze połączenie ogłaszane jest wszystkim,
+// kolejne (oraz strumień SSE, który nie oznacza obecności) dostaje tylko aktualną listę
+func (h *Hub) join(client *Client) {
+	if !client.IsStaff() {
+		return
+	}
+
+	if client.countsPresence() {
+		entry, ok := h.presence[client.UserID]
+		if !ok {
+			entry = &presenceEntry{user: PresenceUser{UserID: client.UserID, Email: client.Email, Role: client.Role}}
+			h.presence[client.UserID] = entry
+		}
+		entry.connections++
+
+		if entry.connections == 1 {
+			h.broadcastPresence(PresenceJoined, client.UserID)
+			return
+		}
+	}
+	h.deliver(client, Message{
+		Type:    TypePresence,
+		Payload: PresenceMessage{Event: PresenceSnapshot, Users: h.presentUsers()},
+	})
+}
+
+// leave - zmiana obecności ogłaszana jest po zakończeniu bieżącej operacji huba
+// (remove może zostać wywołane w trakcie rozsyłania)
+func (h *Hub) leave(client *Client) {
+	entry, ok := h.presence[client.UserID]
+	if !client.countsPresence() || !ok {
+		return
+	}
+	entry.connections--
+	switch {
+	case entry.connections == 0:
+		delete(h.presence, client.UserID)
+		h.pendingPresence = append(h.pendingPresence, pendingPresence{PresenceLeft, entry.user})
+	case len(client.viewing) > 0:
+		h.pendingPresence = append(h.pendingPresence, pendingPresence{PresenceUpdated, entry.user})
+	}
+}
+
+type pendingPresence struct {
+	event string
+	user  PresenceUser
+}
+
+func (h *Hub) flushPresence() {
+	for len(h.pendingPresence) > 0 {
+		p := h.pendingPresence[0]
+		h.pendingPresence = h.pendingPresence[1:]
+		if p.event == PresenceLeft {
+			user := p.user
+			user.Viewing = []int{}
+			h.sendPresence(PresenceMessage{Event: PresenceLeft, User: &user})
+		} else {
+			h.broadcastPresence(p.event, p.user.UserID)
+		}
+	}
+}
+
+// changeView aktualizuje listę zamówień oglądanych przez połączenie
+func (h *Hub) changeView(v viewChange) {
+	client := v.client
+	if !h.Clients[client] || !client.IsStaff() {
+		return
+	}
+	if v.viewing {
+		if client.viewing[v.orderID] || len(client.viewing) >= maxViewedOrders {
+			return
+		}
+		client.viewing[v.orderID] = true
+	} else {
+		if !client.viewing[v.orderID] {
+			return
+		}
+		delete(client.viewing, v.orderID)
+	}
+	h.broadcastPresence(PresenceUpdated, client.UserID)
+}
+
+// broadcastPresence ogłasza zmianę obecności użytkownika
+func (h *Hub) broadcastPresence(event string, userID int) {
+	msg := PresenceMessage{Event: event}
+	users := h.presentUsers()
+	for i := range users {
+		if users[i].UserID == userID {
+			msg.User = &users[i]
+		}
+	}
+	msg.Users = users
+	h.sendPresence(msg)
+}
+
+// sendPresence - obecność jest stanem ulotnym, więc nie trafia do logu zdarzeń
+func (h *Hub) sendPresence(msg PresenceMessage) {
+	if msg.Users == nil {
+		msg.Users = h.presentUsers()
+	}
+	message := Message{Type: TypePresence, Payload: msg, staffOnly: true}
+	for client := range h.Clients {
+		if client.accepts(message) {
+			h.deliver(client, message)
+		}
+	}
+}
+
+// presentUsers zwraca obecnych pracowników z sumą zamówień oglądanych we wszystkich połączeniach
+func (h *Hub) presentUsers() []PresenceUser {
+	viewing := make(map[int]map[int]bool, len(h.presence))
+	for client := range h.Clients {
+		if _, ok := h.presence[client.UserID]; !ok {
+			continue
+		}
+		if viewing[client.UserID] == nil {
+			viewing[client.UserID] = make(map[int]bool)
+		}
+		for orderID := range client.viewing {
+			viewing[client.UserID][orderID] = true
+		}
+	}
+
+	users := make([]PresenceUser, 0, len(h.presence))
+	for userID, entry := range h.presence {
+		user := entry.user
+		user.Viewing = make([]int, 0, len(viewing[userID]))
+		for orderID := range viewing[userID] {
+			user.Viewing = append(user.Viewing, orderID)
+		}
+		sort.Ints(user.Viewing)
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	return users
+}
@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Jak długo hub czeka na brakujący numer, zanim odczyta lukę z logu zdarzeń
+const gapWait = 2 * time.Second
+
+// Jak długo pamiętane są zdarzenia odczytane z logu - ich kopia z fan-out jest pomijana
+const filledTTL = time.Minute
+
+// Numery seq nadaje wspólna sekwencja w bazie, więc zdarzenia z różnych replik mogą dotrzeć
+// w innej kolejności, niż zostały ponumerowane. Hub dostarcza je klientom w kolejności numerów:
+// zdarzenie z luką przed sobą czeka, aż brakujące numery dotrą (z tej lub innej repliki), a po
+// gapWait luka odczytywana jest z logu. Numery, których nadal brak (wycofana transakcja,
+// niezatwierdzony zapis), są pomijane; jeśli takie zdarzenie dotrze później, jest dostarczane
+// poza kolejnością zamiast odrzucane.
+type sequencer struct {
+	// Ostatni numer dostarczony w kolejności (0 - jeszcze nieznany)
+	delivered int64
+	// Zdarzenia czekające na wcześniejsze numery
+	pending map[int64]Message
+	// Numery odczytane z logu podczas wypełniania luki i czas odczytu
+	filled map[int64]time.Time
+
+	timer   *time.Timer
+	reading bool
+	fills   chan gapFill
+}
+
+// Wynik odczytu luki (after, upTo] z logu zdarzeń
+type gapFill struct {
+	upTo     int64
+	messages []Message
+}
+
+func newSequencer() sequencer {
+	return sequencer{
+		pending: make(map[int64]Message),
+		filled:  make(map[int64]time.Time),
+		fills:   make(chan gapFill),
+	}
+}
+
+// startSequence ustawia numer, od którego hub dostarcza zdarzenia w kolejności
+func (h *Hub) startSequence() {
+	if h.events == nil {
+		return
+	}
+	latest, err := h.events.Latest()
+	if err != nil {
+		log.Printf("Błąd odczytu ostatniego zdarzenia WebSocket: %v", err)
+		return
+	}
+	h.seq.delivered = latest
+}
+
+// gapTimeout - kanał timera luki (nil, gdy hub na nic nie czeka)
+func (h *Hub) gapTimeout() <-chan time.Time {
+	if h.seq.timer == nil {
+		return nil
+	}
+	return h.seq.timer.C
+}
+
+// order przekazuje zdarzenie klientom w kolejności numerów
+func (h *Hub) order(message Message) {
+	s := &h.seq
+	switch {
+	case message.Seq == 0 || h.events == nil:
+		// Zdarzenie bez numeru (np. błąd zapisu w logu) - nie da się go uporządkować
+		h.broadcast(message)
+	case s.delivered == 0 || message.Seq == s.delivered+1:
+		s.delivered = message.Seq
+		h.broadcast(message)
+		h.drainPending()
+	case message.Seq <= s.delivered:
+		if _, ok := s.filled[message.Seq]; ok {
+			// Kopia zdarzenia dostarczonego już z logu
+			delete(s.filled, message.Seq)
+			return
+		}
+		h.stats.lateEvents.Add(1)
+		h.broadcast(message)
+	default:
+		s.pending[message.Seq] = message
+		if s.timer == nil && !s.reading {
+			s.timer = time.NewTimer(gapWait)
+		}
+	}
+}
+
+// drainPending dostarcza oczekujące zdarzenia, które są już kolejne
+func (h *Hub) drainPending() {
+	s := &h.seq
+	for {
+		message, ok := s.pending[s.delivered+1]
+		if !ok {
+			break
+		}
+		delete(s.pending, message.Seq)
+		s.delivered = message.Seq
+		h.broadcast(message)
+	}
+	if len(s.pending) == 0 && s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// readGap odczytuje lukę z logu poza gorutyną huba (zapytanie nie blokuje rozsyłania)
+func (h *Hub) readGap() {
+	s := &h.seq
+	s.timer = nil
+	if len(s.pending) == 0 {
+		return
+	}
+
+	after, upTo := s.delivered, s.delivered
+	for seq := range s.pending {
+		upTo = max(upTo, seq)
+	}
+	s.reading = true
+	go func() {
+		messages, err := h.events.between(after, upTo)
+		if err != nil {
+			log.Printf("Błąd odczytu luki w zdarzeniach WebSocket (%d, %d]: %v", after, upTo, err)
+		}
+		s.fills <- gapFill{upTo: upTo, messages: messages}
+	}()
+}
+
+// fillGap dostarcza zdarzenia do numeru upTo: oczekujące oraz odczytane z logu,
+// pomijając numery, których nadal brak
+func (h *Hub) fillGap(fill gapFill) {
+	s := &h.seq
+	s.reading = false
+
+	now := time.Now()
+	for _, message := range fill.messages {
+		if message.Seq <= s.delivered {
+			continue
+		}
+		if _, ok := s.pending[message.Seq]; !ok {
+			s.pending[message.Seq] = message
+			s.filled[message.Seq] = now
+		}
+	}
+
+	var seqs []int64
+	for seq := range s.pending {
+		if seq <= fill.upTo {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	var skipped int64
+	for _, seq := range seqs {
+		if seq > s.delivered {
+			skipped += seq - s.delivered - 1
+			s.delivered = seq
+		}
+		h.broadcast(s.pending[seq])
+		delete(s.pending, seq)
+	}
+	if skipped > 0 {
+		h.stats.skippedSeqs.Add(skipped)
+		log.Printf("Pominięto %d brakujących numerów zdarzeń WebSocket (do #%d)", skipped, s.delivered)
+	}
+	h.drainPending()
+
+	for seq, at := range s.filled {
+		if now.Sub(at) > filledTTL {
+			delete(s.filled, seq)
+		}
+	}
+	if len(s.pending) > 0 && s.timer == nil {
+		s.timer = time.NewTimer(gapWait)
+	}
+}
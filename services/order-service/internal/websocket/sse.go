@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleSSE - GET /api/orders/stream: te same zdarzenia co /ws w formacie Server-Sent Events.
+// Tematy subskrypcji w ?topics= (rozdzielone przecinkami, domyślnie "all"), wznowienie
+// od nagłówka Last-Event-ID (lub ?last_event_id=). Klient rejestrowany jest w hubie jak
+// klient WebSocket, więc obowiązują te same uprawnienia i filtrowanie.
+func HandleSSE(hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := middleware.GetCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user context"})
+			return
+		}
+
+		var lastSeq int64
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		if lastEventID != "" {
+			seq, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil || seq < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+				return
+			}
+			lastSeq = seq
+		}
+
+		client := NewClient(nil, user, hub.config.SendBuffer)
+		client.sse = true
+		if topics := c.Query("topics"); topics != "" {
+			if err := client.SetTopics(strings.Split(topics, ",")); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		latest, missed, err := hub.resume(client, lastSeq)
+		defer func() { hub.Unregister <- client }()
+		if err != nil {
+			log.Printf("Błąd odtwarzania zdarzeń SSE: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay events"})
+			return
+		}
+
+		w := c.Writer
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx nie buforuje strumienia
+		w.WriteHeader(http.StatusOK)
+
+		write := func(message Message) error {
+			rc.SetWriteDeadline(time.Now().Add(hub.config.WriteWait))
+			if err := writeSSE(w, message); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		// Odpowiednik "authenticated" z /ws - numer, od którego trwa strumień
+		missed = append(missed, Message{
+			Type:    "authenticated",
+			Payload: gin.H{"user_id": user.ID, "role": user.Role, "last_seq": latest},
+		})
+		for _, message := range missed {
+			if err := write(message); err != nil {
+				return
+			}
+		}
+
+		// Komentarz jako heartbeat - utrzymuje połączenie przez proxy i wykrywa zerwane połączenia
+		ticker := time.NewTicker(hub.config.pingPeriod())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case message, ok := <-client.Send:
+				if !ok {
+					// Hub zamknął kanał (zbyt wolny klient) - klient wznowi od Last-Event-ID
+					return
+				}
+				if client.replayed[message.Seq] {
+					continue
+				}
+				if err := write(message); err != nil {
+					hub.stats.writeFailures.Add(1)
+					return
+				}
+			case <-ticker.C:
+				rc.SetWriteDeadline(time.Now().Add(hub.config.WriteWait))
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					hub.stats.writeFailures.Add(1)
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeSSE zapisuje wiadomość jako zdarzenie SSE: id (seq, jeśli nadany), event (typ)
+// i data (ta sama koperta JSON co w /ws)
+func writeSSE(w http.ResponseWriter, message Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if message.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", message.Seq); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, data)
+	return err
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iDos27/order-management/order-service/internal/models"
+)
+
+// Tematy subskrypcji:
+//
+//	all               - wszystkie zamówienia (domyślna subskrypcja nowego klienta)
+//	order:<id>        - jedno zamówienie
+//	status:<status>   - zamówienia wchodzące w status lub z niego wychodzące
+//	source:<źródło>   - zamówienia z danego źródła
+//	assigned_to_me    - zamówienia przypisane do zalogowanego użytkownika
+const (
+	TopicAll          = "all"
+	TopicAssignedToMe = "assigned_to_me"
+)
+
+// OrderRef - dane zamówienia, na podstawie których hub dobiera odbiorców wiadomości
+// (zapisywane w logu zdarzeń, aby filtrować również odtwarzane zdarzenia)
+type OrderRef struct {
+	ID             int                `json:"id"`
+	Status         models.OrderStatus `json:"status"`
+	PreviousStatus models.OrderStatus `json:"previous_status,omitempty"`
+	Source         models.OrderSource `json:"source"`
+	AssignedTo     *int               `json:"assigned_to,omitempty"`
+}
+
+// RefFromOrder tworzy OrderRef z modelu zamówienia
+func RefFromOrder(order models.Order) OrderRef {
+	return OrderRef{
+		ID:         order.ID,
+		Status:     order.Status,
+		Source:     order.Source,
+		AssignedTo: order.AssignedTo,
+	}
+}
+
+type subscriptionPayload struct {
+	Topics []string `json:"topics"`
+}
+
+// normalizeTopic sprawdza poprawność tematu i zwraca jego postać kanoniczną
+func normalizeTopic(topic string) (string, error) {
+	topic = strings.TrimSpace(topic)
+	if topic == TopicAll || topic == TopicAssignedToMe {
+		return topic, nil
+	}
+
+	kind, value, ok := strings.Cut(topic, ":")
+	if !ok || value == "" {
+		return "", fmt.Errorf("unknown topic: %q", topic)
+	}
+	switch kind {
+	case "order":
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return "", fmt.Errorf("invalid order ID in topic: %q", topic)
+		}
+		return "order:" + strconv.Itoa(id), nil
+	case "status":
+		if !models.OrderStatus(value).IsValid() {
+			return "", fmt.Errorf("unknown status in topic: %q", topic)
+		}
+		return topic, nil
+	case "source":
+		return topic, nil
+	}
+	return "", fmt.Errorf("unknown topic: %q", topic)
+}
+
+// Subscribe dodaje tematy do subskrypcji klienta
+func (c *Client) Subscribe(topics []string) error {
+	normalized, err := normalizeTopics(topics)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range normalized {
+		c.topics[topic] = true
+	}
+	return nil
+}
+
+// Unsubscribe usuwa tematy z subskrypcji klienta
+func (c *Client) Unsubscribe(topics []string) error {
+	normalized, err := normalizeTopics(topics)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range normalized {
+		delete(c.topics, topic)
+	}
+	return nil
+}
+
+// SetTopics zastępuje subskrypcje klienta podanymi tematami
+func (c *Client) SetTopics(topics []string) error {
+	normalized, err := normalizeTopics(topics)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topics = make(map[string]bool, len(normalized))
+	for _, topic := range normalized {
+		c.topics[topic] = true
+	}
+	return nil
+}
+
+// Topics zwraca posortowaną listę aktualnych subskrypcji
+func (c *Client) Topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// matches sprawdza, czy którekolwiek z zamówień wiadomości pasuje do subskrypcji klienta
+func (c *Client) matches(refs []OrderRef) bool {
+	// Wiadomości niezwiązane z zamówieniami (np. systemowe) trafiają do wszystkich
+	if len(refs) == 0 {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.topics[TopicAll] {
+		return true
+	}
+	for _, ref := range refs {
+		if c.topics["order:"+strconv.Itoa(ref.ID)] ||
+			c.topics["status:"+string(ref.Status)] ||
+			(ref.PreviousStatus != "" && c.topics["status:"+string(ref.PreviousStatus)]) ||
+			c.topics["source:"+string(ref.Source)] ||
+			(c.topics[TopicAssignedToMe] && ref.AssignedTo != nil && *ref.AssignedTo == c.UserID) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeTopics(topics []string) ([]string, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics given")
+	}
+	result := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		normalized, err := normalizeTopic(topic)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, normalized)
+	}
+	return result, nil
+}
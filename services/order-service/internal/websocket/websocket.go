@@ -1,35 +1,177 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iDos27/order-management/order-service/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// Czas na przesłanie wiadomości "auth", jeśli token nie został podany w URL
+const authTimeout = 10 * time.Second
+
+// Pojemność kolejki między handlerami a pętlą huba
+const broadcastBuffer = 1024
+
+type Config struct {
+	// Maksymalny czas oczekiwania na pong (lub dowolną wiadomość) od klienta
+	PongWait time.Duration
+	// Maksymalny czas zapisu jednej wiadomości do klienta
+	WriteWait time.Duration
+	// Pojemność kolejki wiadomości jednego klienta
+	SendBuffer int
+	// Maksymalny rozmiar wiadomości od klienta (bajty)
+	MaxMessageSize int64
+}
+
+// pingPeriod - ping wysyłany jest przed upływem PongWait
+func (c Config) pingPeriod() time.Duration {
+	return c.PongWait * 9 / 10
 }
 
-// Struktura wiadomości
+// TokenValidator weryfikuje token JWT i zwraca dane użytkownika
+type TokenValidator interface {
+	ValidateToken(token string) (*models.CurrentUser, error)
+}
+
+// Struktura wiadomości. Seq - numer zdarzenia w logu (brak dla odpowiedzi do pojedynczego klienta)
 type Message struct {
 	Type    string      `json:"type"`
+	Seq     int64       `json:"seq,omitempty"`
 	Payload interface{} `json:"payload"`
+
+	// Wiadomość tylko dla pracowników oraz zamówienia, których dotyczy
+	// (do dopasowania subskrypcji) - pola nie są serializowane
+	staffOnly bool
+	orders    []OrderRef
+}
+
+// Wiadomość od klienta - payload dekodowany zależnie od typu
+type incomingMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type authPayload struct {
+	Token   string `json:"token"`
+	LastSeq int64  `json:"last_seq"`
+}
+
+// Typy wiadomości rozsyłanych przez hub (schemat w README)
+const (
+	TypeOrderCreated    = "order_created"
+	TypeOrderUpdated    = "order_updated"
+	TypeOrderDeleted    = "order_deleted"
+	TypeOrderBulkUpdate = "order_bulk_update"
+	TypeNoteAdded       = "note_added"
+	TypeSLABreached     = "sla_breached"
+	TypePresence        = "presence"
+	TypeOrderLocked     = "order_locked"
+	TypeOrderUnlocked   = "order_unlocked"
+)
+
+// Pola zamówienia zmienione w order_updated
+const (
+	ChangeStatus     = "status"
+	ChangeTags       = "tags"
+	ChangeAssignment = "assigned_to"
+)
+
+// Zdarzenie zamówienia z pełnym stanem po zmianie (dla order_deleted - stan przed usunięciem).
+// Actor - użytkownik z tokenu JWT, nil dla zmian wykonanych przez system
+type OrderEventMessage struct {
+	OrderId        int                 `json:"order_id"`
+	Order          models.Order        `json:"order"`
+	PreviousStatus models.OrderStatus  `json:"previous_status,omitempty"`
+	Changes        []string            `json:"changes,omitempty"`
+	Actor          *models.CurrentUser `json:"actor"`
+}
+
+// ref - dane do dopasowania subskrypcji
+func (e OrderEventMessage) ref() OrderRef {
+	ref := RefFromOrder(e.Order)
+	ref.PreviousStatus = e.PreviousStatus
+	return ref
 }
 
-// Aktualizacja zamówienia
-type OrderUpdateMessage struct {
-	OrderId   int    `json:"order_id"`
-	NewStatus string `json:"new_status"`
-	UpdatedBy string `json:"updated_by"`
+// Założenie / odnowienie (Lock != nil) lub zwolnienie blokady edycji zamówienia
+type OrderLockMessage struct {
+	OrderId int                 `json:"order_id"`
+	Lock    *models.OrderLock   `json:"lock"`
+	Actor   *models.CurrentUser `json:"actor"`
+}
+
+// Nowa notatka do zamówienia
+type NoteAddedMessage struct {
+	OrderId int                 `json:"order_id"`
+	Note    models.OrderNote    `json:"note"`
+	Actor   *models.CurrentUser `json:"actor"`
 }
 
 type Client struct {
 	Conn *websocket.Conn
 	Send chan Message
+
+	// Tożsamość z tokenu JWT
+	UserID int
+	Email  string
+	Role   string
+
+	mu     sync.Mutex
+	topics map[string]bool
+
+	// Numer, od którego hub dostarcza zdarzenia po rejestracji (wysyłany raz przez hub)
+	registered chan int64
+	// Zdarzenia wysłane podczas odtwarzania - pomijane, jeśli dotrą też przez Send
+	replayed map[int64]bool
+
+	// Zamówienia otwarte w tym połączeniu (dostęp tylko z gorutyny huba)
+	viewing map[int]bool
+
+	// Strumień SSE - tylko odbiera zdarzenia, nie jest liczony w obecności
+	sse bool
+}
+
+// NewClient tworzy klienta z domyślną subskrypcją wszystkich zamówień
+func NewClient(conn *websocket.Conn, user *models.CurrentUser, sendBuffer int) *Client {
+	return &Client{
+		Conn:       conn,
+		Send:       make(chan Message, sendBuffer),
+		UserID:     user.ID,
+		Email:      user.Email,
+		Role:       user.Role,
+		topics:     map[string]bool{TopicAll: true},
+		registered: make(chan int64, 1),
+		replayed:   make(map[int64]bool),
+		viewing:    make(map[int]bool),
+	}
+}
+
+// IsStaff - admin lub pracownik
+func (c *Client) IsStaff() bool {
+	return c.Role == models.RoleAdmin || c.Role == models.RoleEmployee
+}
+
+// countsPresence - obecność liczona jest tylko dla połączeń WebSocket pracowników
+func (c *Client) countsPresence() bool {
+	return c.IsStaff() && !c.sse
+}
+
+// accepts sprawdza uprawnienia i subskrypcje klienta
+func (c *Client) accepts(message Message) bool {
+	if message.staffOnly && !c.IsStaff() {
+		return false
+	}
+	return c.matches(message.orders)
 }
 
 type Hub struct {
@@ -37,117 +179,514 @@ type Hub struct {
 	Register   chan *Client
 	Unregister chan *Client
 	Broadcast  chan Message
+
+	// Odpowiedzi do pojedynczego klienta (np. potwierdzenie subskrypcji)
+	direct chan directMessage
+
+	// Log zdarzeń (nil - bez numeracji i odtwarzania); publishMu gwarantuje,
+	// że lokalne zdarzenia trafiają do huba w kolejności numerów
+	events    *EventLog
+	publishMu sync.Mutex
+
+	// Dostarczanie zdarzeń w kolejności numerów (dostęp tylko z gorutyny Run)
+	seq sequencer
+
+	// Fan-out do pozostałych replik (nil - pojedyncza replika)
+	fanout Fanout
+
+	config Config
+	stats  hubCounters
+
+	// Obecność pracowników (dostępna tylko w gorutynie Run): liczba połączeń użytkownika
+	// oraz użytkownicy, których ostatnie połączenie właśnie zostało zamknięte
+	presence        map[int]*presenceEntry
+	pendingPresence []pendingPresence
+	views           chan viewChange
+}
+
+type directMessage struct {
+	client  *Client
+	message Message
+}
+
+// Liczniki huba (atomowe - odczytywane przez endpoint statystyk)
+type hubCounters struct {
+	clients         atomic.Int64
+	broadcast       atomic.Int64
+	delivered       atomic.Int64
+	dropped         atomic.Int64
+	slowDisconnects atomic.Int64
+	pongTimeouts    atomic.Int64
+	writeFailures   atomic.Int64
+	lateEvents      atomic.Int64
+	skippedSeqs     atomic.Int64
+}
+
+// HubStats - statystyki huba zwracane przez GET /api/ws/stats
+type HubStats struct {
+	ConnectedClients      int64 `json:"connected_clients"`
+	MessagesBroadcast     int64 `json:"messages_broadcast"`
+	MessagesDelivered     int64 `json:"messages_delivered"`
+	MessagesDropped       int64 `json:"messages_dropped"`
+	SlowClientDisconnects int64 `json:"slow_client_disconnects"`
+	PongTimeouts          int64 `json:"pong_timeouts"`
+	WriteFailures         int64 `json:"write_failures"`
+	LateEvents            int64 `json:"late_events"`
+	SkippedSeqs           int64 `json:"skipped_seqs"`
+	PendingBroadcasts     int   `json:"pending_broadcasts"`
 }
 
 // Tworzenie huba WebSocketów
-func NewHub() *Hub {
+func NewHub(events *EventLog, fanout Fanout, config Config) *Hub {
 	return &Hub{
 		Clients:    make(map[*Client]bool),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
-		Broadcast:  make(chan Message),
+		Broadcast:  make(chan Message, broadcastBuffer),
+		direct:     make(chan directMessage),
+		events:     events,
+		fanout:     fanout,
+		config:     config,
+		seq:        newSequencer(),
+		presence:   make(map[int]*presenceEntry),
+		views:      make(chan viewChange),
+	}
+}
+
+// Stats zwraca bieżące statystyki huba
+func (h *Hub) Stats() HubStats {
+	return HubStats{
+		ConnectedClients:      h.stats.clients.Load(),
+		MessagesBroadcast:     h.stats.broadcast.Load(),
+		MessagesDelivered:     h.stats.delivered.Load(),
+		MessagesDropped:       h.stats.dropped.Load(),
+		SlowClientDisconnects: h.stats.slowDisconnects.Load(),
+		PongTimeouts:          h.stats.pongTimeouts.Load(),
+		WriteFailures:         h.stats.writeFailures.Load(),
+		LateEvents:            h.stats.lateEvents.Load(),
+		SkippedSeqs:           h.stats.skippedSeqs.Load(),
+		PendingBroadcasts:     len(h.Broadcast),
+	}
+}
+
+// HandleStats - GET /api/ws/stats
+func HandleStats(hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, hub.Stats())
 	}
 }
 
 // Uruchomienie huba
 func (h *Hub) Run() {
+	h.startSequence()
+
 	for {
 		select {
 		case client := <-h.Register:
 			h.Clients[client] = true
+			h.stats.clients.Add(1)
+			h.join(client)
+			// Zdarzenia do tego numeru klient odtwarza z logu, kolejne dostanie przez Send
+			client.registered <- h.seq.delivered
 			log.Println("Nowy klient połączony")
 		case client := <-h.Unregister:
 			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				close(client.Send)
+				h.remove(client)
 				log.Println("Klient rozłączony")
 			}
 		case message := <-h.Broadcast:
-			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+			h.order(message)
+		case <-h.gapTimeout():
+			h.readGap()
+		case fill := <-h.seq.fills:
+			h.fillGap(fill)
+		case d := <-h.direct:
+			// Klient mógł zostać w międzyczasie wyrejestrowany - wtedy Send jest już zamknięty
+			if h.Clients[d.client] {
+				h.deliver(d.client, d.message)
 			}
+		case v := <-h.views:
+			h.changeView(v)
 		}
+		h.flushPresence()
 	}
 }
 
-func (h *Hub) BroadcastOrderUpdate(orderId int, newStatus, updatedBy string) {
-	message := Message{
-		Type: "order_update",
-		Payload: OrderUpdateMessage{
-			OrderId:   orderId,
-			NewStatus: newStatus,
-			UpdatedBy: updatedBy,
-		},
+// broadcast przekazuje wiadomość wszystkim klientom, którzy ją akceptują
+func (h *Hub) broadcast(message Message) {
+	h.stats.broadcast.Add(1)
+	for client := range h.Clients {
+		if !client.accepts(message) {
+			continue
+		}
+		h.deliver(client, message)
 	}
+}
+
+// deliver umieszcza wiadomość w kolejce klienta bez blokowania pętli huba. Pełna kolejka
+// oznacza zbyt wolnego klienta - jest rozłączany i wznawia połączenie od ostatniego seq
+func (h *Hub) deliver(client *Client, message Message) {
 	select {
-	case h.Broadcast <- message:
-		log.Printf("Broadcasting order update: %+v", message)
+	case client.Send <- message:
+		h.stats.delivered.Add(1)
 	default:
-		log.Println("Nie można wysłać wiadomości, brak połączonych klientów")
+		h.stats.dropped.Add(1)
+		h.stats.slowDisconnects.Add(1)
+		log.Printf("Kolejka klienta (użytkownik %d) pełna - rozłączam wolnego klienta", client.UserID)
+		h.remove(client)
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	delete(h.Clients, client)
+	close(client.Send)
+	h.stats.clients.Add(-1)
+	h.leave(client)
+}
+
+// sendTo wysyła wiadomość tylko do wskazanego klienta
+func (h *Hub) sendTo(client *Client, message Message) {
+	h.direct <- directMessage{client: client, message: message}
+}
+
+// publish nadaje wiadomości numer w logu zdarzeń i przekazuje ją do huba. Kolejka huba jest
+// buforowana, a pętla huba nigdy nie blokuje się na klientach, więc oczekiwanie jest krótkie
+// i żadna wiadomość nie jest gubiona
+func (h *Hub) publish(message Message) {
+	h.publishMu.Lock()
+	defer h.publishMu.Unlock()
+
+	if h.events != nil {
+		seq, err := h.events.Append(message)
+		if err != nil {
+			// Wiadomość i tak trafia do połączonych klientów, ale nie da się jej odtworzyć
+			log.Printf("Błąd zapisu zdarzenia %s w logu: %v", message.Type, err)
+		} else {
+			message.Seq = seq
+		}
+	}
+
+	h.Broadcast <- message
+
+	if h.fanout != nil {
+		if err := h.fanout.Publish(message); err != nil {
+			log.Printf("Błąd fan-out zdarzenia %s do innych replik: %v", message.Type, err)
+		}
+	}
+}
+
+// BroadcastRemote rozgłasza lokalnie zdarzenie otrzymane od innej repliki
+// (jest już zapisane w logu, więc nie jest ponownie numerowane ani publikowane)
+func (h *Hub) BroadcastRemote(message Message) {
+	h.Broadcast <- message
+}
+
+// BroadcastOrderEvent wysyła order_created / order_updated / order_deleted
+func (h *Hub) BroadcastOrderEvent(messageType string, event OrderEventMessage) {
+	event.OrderId = event.Order.ID
+	message := Message{
+		Type:      messageType,
+		Payload:   event,
+		staffOnly: true,
+		orders:    []OrderRef{event.ref()},
+	}
+	h.publish(message)
+	log.Printf("Broadcasting %s: zamówienie #%d %v", messageType, event.OrderId, event.Changes)
+}
+
+// BroadcastEvent wysyła wiadomość danego typu do klientów subskrybujących podane zamówienia
+func (h *Hub) BroadcastEvent(messageType string, payload interface{}, refs ...OrderRef) {
+	message := Message{
+		Type:      messageType,
+		Payload:   payload,
+		staffOnly: true,
+		orders:    refs,
+	}
+	h.publish(message)
+	log.Printf("Broadcasting %s: %+v", messageType, payload)
+}
+
+// BroadcastBulkOrderUpdate wysyła jedną wiadomość ze zmianami wielu zamówień
+// (klient otrzymuje ją, jeśli subskrybuje którekolwiek z zamówień)
+func (h *Hub) BroadcastBulkOrderUpdate(updates []OrderEventMessage) {
+	refs := make([]OrderRef, 0, len(updates))
+	for i := range updates {
+		updates[i].OrderId = updates[i].Order.ID
+		refs = append(refs, updates[i].ref())
 	}
+	message := Message{
+		Type:      TypeOrderBulkUpdate,
+		Payload:   updates,
+		staffOnly: true,
+		orders:    refs,
+	}
+	h.publish(message)
+	log.Printf("Broadcasting bulk order update: %d zamówień", len(updates))
 }
 
-func HandleWebSocket(hub *Hub) gin.HandlerFunc {
+// HandleWebSocket - połączenie wymaga tokenu JWT: w parametrze ?token= albo w pierwszej
+// wiadomości {"type": "auth", "payload": {"token": "..."}}. Origin musi być na liście dozwolonych.
+// Klient wznawiający połączenie podaje numer ostatniego otrzymanego zdarzenia
+// (?last_seq= lub pole last_seq wiadomości auth) i otrzymuje zdarzenia, które go ominęły.
+func HandleWebSocket(hub *Hub, auth TokenValidator, allowedOrigins []string) gin.HandlerFunc {
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[origin] = true
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			// Brak nagłówka Origin - klient spoza przeglądarki, tożsamość potwierdza token
+			origin := r.Header.Get("Origin")
+			return origin == "" || origins[origin]
+		},
+	}
+
 	return func(c *gin.Context) {
+		// Token w URL weryfikujemy przed upgrade, aby móc odpowiedzieć zwykłym 401
+		var user *models.CurrentUser
+		var lastSeq int64
+		if v := c.Query("last_seq"); v != "" {
+			seq, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || seq < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last_seq"})
+				return
+			}
+			lastSeq = seq
+		}
+		if token := c.Query("token"); token != "" {
+			var err error
+			user, err = auth.ValidateToken(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+		}
+
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			log.Println("Błąd aktualizacji WebSocket:", err)
 			return
 		}
 
-		client := &Client{
-			Conn: conn,
-			Send: make(chan Message, 256),
+		if user == nil {
+			var payload authPayload
+			user, payload, err = authenticate(conn, auth)
+			if payload.LastSeq > 0 {
+				lastSeq = payload.LastSeq
+			}
+			if err != nil {
+				log.Printf("Odrzucono połączenie WebSocket: %v", err)
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "authentication required"),
+					time.Now().Add(time.Second))
+				conn.Close()
+				return
+			}
 		}
 
-		hub.Register <- client
+		client := NewClient(conn, user, hub.config.SendBuffer)
 
-		go writePump(client)
+		// Nowe zdarzenia czekają w client.Send, a duplikaty odtworzonych zdarzeń pomija writePump
+		latest, missed, err := hub.resume(client, lastSeq)
+		if err != nil {
+			log.Printf("Błąd odtwarzania zdarzeń WebSocket: %v", err)
+			hub.Unregister <- client
+			conn.Close()
+			return
+		}
+
+		// writePump jeszcze nie działa - piszemy bezpośrednio do połączenia
+		missed = append(missed, Message{
+			Type:    "authenticated",
+			Payload: gin.H{"user_id": user.ID, "role": user.Role, "last_seq": latest},
+		})
+		for _, message := range missed {
+			conn.SetWriteDeadline(time.Now().Add(hub.config.WriteWait))
+			if err := conn.WriteJSON(message); err != nil {
+				hub.Unregister <- client
+				conn.Close()
+				return
+			}
+		}
+
+		go writePump(client, hub)
 		go readPump(client, hub)
 	}
 }
 
-// readPump - odbiera wiadomości od klienta
+// resume rejestruje klienta w hubie i zwraca numer ostatniego dostarczonego przez hub zdarzenia
+// oraz wiadomości, które klient powinien dostać przed bieżącymi: zdarzenia o numerach większych
+// niż lastSeq albo "resync_required", jeśli nie da się ich odtworzyć. Przy błędzie klient jest
+// już zarejestrowany.
+func (h *Hub) resume(client *Client, lastSeq int64) (int64, []Message, error) {
+	// Hub podaje numer w chwili rejestracji - zdarzenia o numerach <= latest zostały już
+	// rozesłane, a każde kolejne trafi do client.Send
+	h.Register <- client
+	latest := <-client.registered
+	if h.events == nil || lastSeq == 0 {
+		return latest, nil, nil
+	}
+
+	messages, ok, err := h.events.Since(lastSeq, latest)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ok {
+		// Klient musi pobrać aktualny stan przez REST API i kontynuować od last_seq
+		return latest, []Message{{
+			Type:    "resync_required",
+			Payload: gin.H{"last_seq": latest},
+		}}, nil
+	}
+
+	var missed []Message
+	for _, message := range messages {
+		client.replayed[message.Seq] = true
+		if client.accepts(message) {
+			missed = append(missed, message)
+		}
+	}
+	return latest, missed, nil
+}
+
+// authenticate czeka na pierwszą wiadomość typu "auth" z tokenem JWT
+func authenticate(conn *websocket.Conn, auth TokenValidator) (*models.CurrentUser, authPayload, error) {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var payload authPayload
+	var msg incomingMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, payload, err
+	}
+	if msg.Type != "auth" {
+		return nil, payload, errors.New("pierwsza wiadomość musi być typu auth")
+	}
+
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Token == "" {
+		return nil, payload, errors.New("brak tokenu w wiadomości auth")
+	}
+	user, err := auth.ValidateToken(payload.Token)
+	return user, payload, err
+}
+
+// readPump - odbiera wiadomości od klienta. Brak ponga (ani innej wiadomości) przez PongWait
+// kończy połączenie, dzięki czemu półotwarte połączenia nie wiszą w hubie
 func readPump(client *Client, hub *Hub) {
 	defer func() {
 		hub.Unregister <- client
 		client.Conn.Close()
 	}()
 
+	client.Conn.SetReadLimit(hub.config.MaxMessageSize)
+	client.Conn.SetReadDeadline(time.Now().Add(hub.config.PongWait))
+	client.Conn.SetPongHandler(func(string) error {
+		return client.Conn.SetReadDeadline(time.Now().Add(hub.config.PongWait))
+	})
+
 	for {
-		var msg Message
+		var msg incomingMessage
 		err := client.Conn.ReadJSON(&msg)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				hub.stats.pongTimeouts.Add(1)
+				log.Printf("Brak odpowiedzi na ping od klienta (użytkownik %d) - rozłączam", client.UserID)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Błąd WebSocket: %v", err)
 			}
 			break
 		}
+		client.Conn.SetReadDeadline(time.Now().Add(hub.config.PongWait))
 
-		// Tutaj możemy obsłużyć wiadomości od klienta
-		log.Printf("Otrzymano wiadomość: %s", msg.Type)
+		handleClientMessage(client, hub, msg)
 	}
 }
 
-// writePump - wysyła wiadomości do klienta
-func writePump(client *Client) {
-	defer client.Conn.Close()
-
-	// Pętla range automatycznie:
-	// 1. Czeka na wiadomość z kanału client.Send
-	// 2. Przerywa pętlę, gdy kanał zostanie zamknięty (w Hubie przez close(client.Send))
-	for message := range client.Send {
-		if err := client.Conn.WriteJSON(message); err != nil {
-			log.Printf("Błąd wysyłania: %v", err)
+// handleClientMessage obsługuje polecenia klienta: subscribe / unsubscribe ({"topics": [...]}),
+// list_subscriptions oraz view_order / leave_order ({"order_id": 123})
+func handleClientMessage(client *Client, hub *Hub, msg incomingMessage) {
+	switch msg.Type {
+	case "view_order", "leave_order":
+		var payload struct {
+			OrderID int `json:"order_id"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.OrderID <= 0 {
+			hub.sendTo(client, errorMessage(msg.Type, "invalid payload"))
 			return
 		}
+		hub.views <- viewChange{client: client, orderID: payload.OrderID, viewing: msg.Type == "view_order"}
+	case "subscribe", "unsubscribe":
+		var payload subscriptionPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			hub.sendTo(client, errorMessage(msg.Type, "invalid payload"))
+			return
+		}
+		var err error
+		if msg.Type == "subscribe" {
+			err = client.Subscribe(payload.Topics)
+		} else {
+			err = client.Unsubscribe(payload.Topics)
+		}
+		if err != nil {
+			hub.sendTo(client, errorMessage(msg.Type, err.Error()))
+			return
+		}
+		hub.sendTo(client, subscriptionsMessage(client))
+	case "list_subscriptions":
+		hub.sendTo(client, subscriptionsMessage(client))
+	default:
+		log.Printf("Otrzymano nieobsługiwaną wiadomość: %s", msg.Type)
+		hub.sendTo(client, errorMessage(msg.Type, "unsupported message type"))
 	}
+}
+
+func subscriptionsMessage(client *Client) Message {
+	return Message{
+		Type:    "subscriptions",
+		Payload: subscriptionPayload{Topics: client.Topics()},
+	}
+}
+
+func errorMessage(requestType, reason string) Message {
+	return Message{
+		Type:    "error",
+		Payload: gin.H{"request": requestType, "error": reason},
+	}
+}
 
-	// Ten kod wykona się tylko, gdy kanał client.Send zostanie zamknięty
-	client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+// writePump - wysyła wiadomości do klienta oraz cykliczne pingi; każdy zapis ma limit czasu
+// WriteWait, więc zablokowane połączenie nie zatrzymuje gorutyny na zawsze
+func writePump(client *Client, hub *Hub) {
+	ticker := time.NewTicker(hub.config.pingPeriod())
+	defer func() {
+		ticker.Stop()
+		client.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.Send:
+			client.Conn.SetWriteDeadline(time.Now().Add(hub.config.WriteWait))
+			if !ok {
+				// Hub zamknął kanał (rozłączenie lub zbyt wolny klient)
+				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if client.replayed[message.Seq] {
+				continue
+			}
+			if err := client.Conn.WriteJSON(message); err != nil {
+				hub.stats.writeFailures.Add(1)
+				log.Printf("Błąd wysyłania: %v", err)
+				return
+			}
+		case <-ticker.C:
+			client.Conn.SetWriteDeadline(time.Now().Add(hub.config.WriteWait))
+			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				hub.stats.writeFailures.Add(1)
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logger - log dostępu w formacie gin.Logger, ale z zamaskowanym parametrem ?token=
+// (WebSocket przyjmuje token JWT w URL, a nie może on trafić do logów)
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			maskToken(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// maskToken zastępuje wartość parametru token w ścieżce z zapytaniem
+func maskToken(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Niepoprawnego zapytania nie da się bezpiecznie zamaskować - pomijamy je w logu
+		return base + "?<invalid query>"
+	}
+	if _, found := query["token"]; !found {
+		return path
+	}
+	query.Set("token", "redacted")
+	return base + "?" + query.Encode()
+}
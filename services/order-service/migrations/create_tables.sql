@@ -17,6 +17,101 @@ CREATE TABLE IF NOT EXISTS order_items (
     price DECIMAL(10,2) NOT NULL
 );
 
+-- Tagi i przypisanie pracownika (ID użytkownika z auth-service)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS assigned_to INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_orders_tags ON orders USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_orders_assigned_to ON orders (assigned_to);
+
+-- Monitorowanie SLA: moment wejścia w bieżący status (NULL - brak zmiany od utworzenia, liczymy od updated_at)
+-- oraz moment oznaczenia przekroczenia SLA (zerowany przy zmianie statusu)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS sla_breached_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+
+-- Język e-maili wysyłanych do klienta przez notification-service
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_locale VARCHAR(8) NOT NULL DEFAULT 'pl';
+
+-- Wewnętrzne notatki pracowników
+CREATE TABLE IF NOT EXISTS order_notes (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL,
+    author_email VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_notes_order ON order_notes (order_id, created_at);
+
+-- Załączniki zamówień (zawartość w storage pod storage_key)
+CREATE TABLE IF NOT EXISTS order_attachments (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    uploaded_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_attachments_order ON order_attachments (order_id);
+
+-- Subskrypcje webhooków partnerów (puste events/sources = wszystkie)
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    sources TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Log dostarczeń webhooków
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'success', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription
+    ON webhook_deliveries (subscription_id, created_at DESC);
+
+-- Doradcze blokady edycji zamówień (wygasają po expires_at)
+CREATE TABLE IF NOT EXISTS order_locks (
+    order_id INTEGER PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    user_email VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Log zdarzeń WebSocket - seq to numer sekwencyjny do wznawiania połączeń
+CREATE TABLE IF NOT EXISTS ws_events (
+    seq BIGSERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    staff_only BOOLEAN NOT NULL DEFAULT TRUE,
+    orders JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_ws_events_created_at ON ws_events (created_at);
+
 -- Wstawienie przykładowych zamówień z różnych miesięcy (2025)
 -- Równomierny rozkład po statusach: new(4), confirmed(4), shipped(4), delivered(4), cancelled(3)
 -- Sierpień 2025